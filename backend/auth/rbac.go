@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("角色不存在")
+	ErrRoleExists        = errors.New("角色已存在")
+	ErrRoleBuiltin       = errors.New("内置角色不能删除")
+	ErrRoleInUse         = errors.New("仍有用户使用该角色，不能删除")
+	ErrAdminRoleFixed    = errors.New("admin 角色始终拥有全部权限，不能修改")
+	ErrUnknownPermission = errors.New("未知的权限")
+)
+
+// 权限，按操作划分，路由通过 RequirePermission 要求其中之一
+const (
+	PermBooksCreate      = "books:create"      // 新增图书
+	PermBooksUpdate      = "books:update"      // 修改图书信息、分类和标签
+	PermBooksDelete      = "books:delete"      // 归档、恢复、彻底删除图书
+	PermBooksImport      = "books:import"      // 批量导入图书
+	PermCopiesManage     = "copies:manage"     // 管理单册
+	PermCategoriesManage = "categories:manage" // 管理分类和标签
+	PermCirculationDesk  = "circulation:desk"  // 服务台扫码归还任意读者的单册
+	PermRecordsRead      = "records:read"      // 查看读者的借阅记录和预约
+	PermFeesManage       = "fees:manage"       // 登记、收取、减免费用
+	PermExportBooks      = "export:books"      // 导出图书
+	PermExportRecords    = "export:records"    // 导出借阅记录
+	PermAPIKeysManage    = "api-keys:manage"   // 管理所有用户的 API Key
+	PermUsersManage      = "users:manage"      // 管理用户和服务账号
+	PermRolesManage      = "roles:manage"      // 管理角色和权限分配
+)
+
+// 内置角色名，新注册的读者为 user
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+const permissionCacheMaxAge = 30 * time.Second
+
+// Permissions 全部权限及说明，启动时同步到数据库
+var Permissions = map[string]string{
+	PermBooksCreate:      "新增图书",
+	PermBooksUpdate:      "修改图书信息、分类和标签",
+	PermBooksDelete:      "归档、恢复、彻底删除图书",
+	PermBooksImport:      "批量导入图书",
+	PermCopiesManage:     "管理单册",
+	PermCategoriesManage: "管理分类和标签",
+	PermCirculationDesk:  "服务台扫码归还任意读者的单册",
+	PermRecordsRead:      "查看读者的借阅记录和预约",
+	PermFeesManage:       "登记、收取、减免费用",
+	PermExportBooks:      "导出图书",
+	PermExportRecords:    "导出借阅记录",
+	PermAPIKeysManage:    "管理所有用户的 API Key",
+	PermUsersManage:      "管理用户和服务账号",
+	PermRolesManage:      "管理角色和权限分配",
+}
+
+// builtinRoles 内置角色的初始权限，admin 每次启动时补全为全部权限，其余角色只在首次创建时写入
+var builtinRoles = []struct {
+	name        string
+	description string
+	permissions []string
+}{
+	{RoleAdmin, "管理员，拥有全部权限", nil},
+	{RoleUser, "读者，只能借阅、预约和查询自己的记录", []string{}},
+	{"librarian", "流通馆员，办理借还、查看读者记录和收费，不能修改馆藏", []string{
+		PermCirculationDesk, PermRecordsRead, PermFeesManage, PermExportRecords,
+	}},
+	{"cataloguer", "编目员，维护图书、单册和分类，不能查看读者记录", []string{
+		PermBooksCreate, PermBooksUpdate, PermBooksImport, PermCopiesManage, PermCategoriesManage, PermExportBooks,
+	}},
+}
+
+// SeedRoles 同步权限列表并创建缺少的内置角色，可重复执行
+func SeedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for code, desc := range Permissions {
+			p := models.Permission{Code: code, Description: desc}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "code"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description"}),
+			}).Create(&p).Error; err != nil {
+				return err
+			}
+		}
+
+		var all []models.Permission
+		if err := tx.Find(&all).Error; err != nil {
+			return err
+		}
+
+		for _, br := range builtinRoles {
+			var role models.Role
+			err := tx.Where("name = ?", br.name).First(&role).Error
+			if err == nil && br.name != RoleAdmin {
+				continue
+			}
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			role.Name, role.Description, role.Builtin = br.name, br.description, true
+			if err := tx.Save(&role).Error; err != nil {
+				return err
+			}
+			perms := all
+			if br.name != RoleAdmin {
+				if perms, err = findPermissions(tx, br.permissions); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&role).Association("Permissions").Replace(perms); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// findPermissions 按 code 查询权限，有未知 code 时返回 ErrUnknownPermission
+func findPermissions(tx *gorm.DB, codes []string) ([]models.Permission, error) {
+	perms := []models.Permission{}
+	if len(codes) == 0 {
+		return perms, nil
+	}
+	if err := tx.Where("code IN ?", codes).Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(perms))
+	for _, p := range perms {
+		found[p.Code] = true
+	}
+	for _, code := range codes {
+		if !found[code] {
+			return nil, ErrUnknownPermission
+		}
+	}
+	return perms, nil
+}
+
+// SaveRole 创建或修改角色并设置其权限，角色名创建后不可修改（User.Role 按名称引用）
+func SaveRole(db *gorm.DB, role *models.Role, codes []string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if role.ID == 0 {
+			var count int64
+			if err := tx.Model(&models.Role{}).Where("name = ?", role.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrRoleExists
+			}
+		} else if role.Name == RoleAdmin {
+			return ErrAdminRoleFixed
+		}
+
+		perms, err := findPermissions(tx, codes)
+		if err != nil {
+			return err
+		}
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(role).Association("Permissions").Replace(perms); err != nil {
+			return err
+		}
+		role.Permissions = perms
+		return nil
+	})
+	if err == nil {
+		InvalidatePermissions()
+	}
+	return err
+}
+
+// DeleteRole 删除自定义角色，内置角色和仍被用户使用的角色不能删除
+func DeleteRole(db *gorm.DB, id uint64) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&role, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if role.Builtin {
+			return ErrRoleBuiltin
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleInUse
+		}
+		return tx.Select("Permissions").Delete(&role).Error
+	})
+	if err == nil {
+		InvalidatePermissions()
+	}
+	return err
+}
+
+// RoleExists 判断角色是否存在
+func RoleExists(db *gorm.DB, name string) (bool, error) {
+	perms, err := loadPermissions(db)
+	if err != nil {
+		return false, err
+	}
+	_, ok := perms[name]
+	return ok, nil
+}
+
+// permissionCache 角色到权限集合的缓存，角色变更时本进程立即失效，其他实例最多延迟 permissionCacheMaxAge
+var permissionCache struct {
+	sync.Mutex
+	roles    map[string]map[string]bool
+	loadedAt time.Time
+}
+
+// InvalidatePermissions 清空权限缓存，下次检查时从数据库重新加载
+func InvalidatePermissions() {
+	permissionCache.Lock()
+	permissionCache.roles = nil
+	permissionCache.Unlock()
+}
+
+func loadPermissions(db *gorm.DB) (map[string]map[string]bool, error) {
+	permissionCache.Lock()
+	defer permissionCache.Unlock()
+
+	if permissionCache.roles != nil && time.Since(permissionCache.loadedAt) < permissionCacheMaxAge {
+		return permissionCache.roles, nil
+	}
+
+	var roles []models.Role
+	if err := db.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]bool, len(roles))
+	for _, r := range roles {
+		set := make(map[string]bool, len(r.Permissions))
+		for _, p := range r.Permissions {
+			set[p.Code] = true
+		}
+		result[r.Name] = set
+	}
+	permissionCache.roles = result
+	permissionCache.loadedAt = time.Now()
+	return result, nil
+}
+
+// RoleHasPermission 判断角色是否拥有指定权限，未知角色没有任何权限
+func RoleHasPermission(db *gorm.DB, role, perm string) (bool, error) {
+	perms, err := loadPermissions(db)
+	if err != nil {
+		return false, err
+	}
+	return perms[role][perm], nil
+}
+
+// IsStaffRole 判断角色是否拥有任一权限，即不是普通读者
+func IsStaffRole(db *gorm.DB, role string) (bool, error) {
+	perms, err := loadPermissions(db)
+	if err != nil {
+		return false, err
+	}
+	return len(perms[role]) > 0, nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRoleDB 使用内存 SQLite 建立角色和权限表并写入内置角色
+func newTestRoleDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared&_pragma=foreign_keys(1)"),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	if err := SeedRoles(db); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+	InvalidatePermissions()
+	t.Cleanup(InvalidatePermissions)
+	return db
+}
+
+func TestBuiltinRolePermissions(t *testing.T) {
+	db := newTestRoleDB(t)
+
+	tests := []struct {
+		role string
+		perm string
+		want bool
+	}{
+		{RoleAdmin, PermRolesManage, true},
+		{RoleAdmin, PermUsersManage, true},
+		{RoleUser, PermRecordsRead, false},
+		{"librarian", PermCirculationDesk, true},
+		{"librarian", PermFeesManage, true},
+		{"librarian", PermBooksCreate, false},
+		{"librarian", PermUsersManage, false},
+		{"librarian", PermRolesManage, false},
+		{"librarian", PermAPIKeysManage, false},
+		{"cataloguer", PermBooksCreate, true},
+		{"cataloguer", PermCopiesManage, true},
+		{"cataloguer", PermRecordsRead, false},
+		{"cataloguer", PermFeesManage, false},
+		{"cataloguer", PermUsersManage, false},
+		{"cataloguer", PermRolesManage, false},
+		{"nobody", PermBooksCreate, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+"/"+tt.perm, func(t *testing.T) {
+			got, err := RoleHasPermission(db, tt.role, tt.perm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RoleHasPermission(%s, %s) = %t，期望 %t", tt.role, tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveRole(t *testing.T) {
+	db := newTestRoleDB(t)
+
+	var admin models.Role
+	if err := db.Where("name = ?", RoleAdmin).First(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveRole(db, &admin, []string{PermBooksCreate}); !errors.Is(err, ErrAdminRoleFixed) {
+		t.Errorf("修改 admin 角色返回 %v，期望 ErrAdminRoleFixed", err)
+	}
+	if ok, _ := RoleHasPermission(db, RoleAdmin, PermRolesManage); !ok {
+		t.Error("admin 角色的权限被修改")
+	}
+
+	if err := SaveRole(db, &models.Role{Name: RoleAdmin}, nil); !errors.Is(err, ErrRoleExists) {
+		t.Errorf("新建同名 admin 角色返回 %v，期望 ErrRoleExists", err)
+	}
+	if err := SaveRole(db, &models.Role{Name: "auditor"}, []string{"books:everything"}); !errors.Is(err, ErrUnknownPermission) {
+		t.Errorf("使用未知权限返回 %v，期望 ErrUnknownPermission", err)
+	}
+
+	// 修改内置角色后缓存立即失效，重新初始化不会覆盖修改
+	var librarian models.Role
+	if err := db.Where("name = ?", "librarian").First(&librarian).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := RoleHasPermission(db, "librarian", PermFeesManage); !ok {
+		t.Fatal("librarian 初始应有 fees:manage 权限")
+	}
+	if err := SaveRole(db, &librarian, []string{PermCirculationDesk}); err != nil {
+		t.Fatalf("修改 librarian 角色失败: %v", err)
+	}
+	if ok, _ := RoleHasPermission(db, "librarian", PermFeesManage); ok {
+		t.Error("修改后的 librarian 角色仍有 fees:manage 权限")
+	}
+	if err := SeedRoles(db); err != nil {
+		t.Fatal(err)
+	}
+	InvalidatePermissions()
+	if ok, _ := RoleHasPermission(db, "librarian", PermFeesManage); ok {
+		t.Error("重新初始化覆盖了 librarian 角色的修改")
+	}
+}
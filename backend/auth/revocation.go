@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RevocationList 已吊销令牌的 ID 列表，记录保留到令牌过期
+// Revoke 是原子的检查并写入，返回本次调用是否新吊销了该令牌，已吊销或已过期时返回 false
+// 另按用户记录一个时间点，该时间之前签发的令牌和建立的会话全部失效，记录保留 ttl
+type RevocationList interface {
+	Revoke(id string, until time.Time) (bool, error)
+	IsRevoked(id string) (bool, error)
+	RevokeUser(userID uint, before time.Time, ttl time.Duration) error
+	UserRevokedBefore(userID uint) (time.Time, error)
+}
+
+// Revocations 全局吊销列表，启动时按 Redis 是否可用选择实现；进程内实现只对单实例有效
+var Revocations RevocationList = NewMemoryRevocations()
+
+// MemoryRevocations 进程内吊销列表，重启后丢失
+type MemoryRevocations struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	users   map[uint]time.Time
+}
+
+func NewMemoryRevocations() *MemoryRevocations {
+	return &MemoryRevocations{entries: make(map[string]time.Time), users: make(map[uint]time.Time)}
+}
+
+func (m *MemoryRevocations) Revoke(id string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 顺带清理已过期的记录
+	now := time.Now()
+	for k, exp := range m.entries {
+		if now.After(exp) {
+			delete(m.entries, k)
+		}
+	}
+	if !until.After(now) {
+		return false, nil
+	}
+	if _, ok := m.entries[id]; ok {
+		return false, nil
+	}
+	m.entries[id] = until
+	return true, nil
+}
+
+func (m *MemoryRevocations) IsRevoked(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.entries[id]
+	return ok && time.Now().Before(until), nil
+}
+
+// RevokeUser 用户级记录数量与用户数相当，进程内不必过期清理
+func (m *MemoryRevocations) RevokeUser(userID uint, before time.Time, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[userID] = before
+	return nil
+}
+
+func (m *MemoryRevocations) UserRevokedBefore(userID uint) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.users[userID], nil
+}
+
+const (
+	revokedKeyPrefix     = "auth:revoked:"
+	revokedUserKeyPrefix = "auth:revoked_user:"
+)
+
+// RedisRevocations 吊销记录保存在 Redis 中，多实例共享，过期时间与令牌一致
+type RedisRevocations struct {
+	pool *redis.Pool
+}
+
+func NewRedisRevocations(pool *redis.Pool) *RedisRevocations {
+	return &RedisRevocations{pool: pool}
+}
+
+func (r *RedisRevocations) Revoke(id string, until time.Time) (bool, error) {
+	ttl := time.Until(until).Milliseconds()
+	if ttl <= 0 {
+		return false, nil
+	}
+	conn := r.pool.Get()
+	defer conn.Close()
+	// NX 保证并发吊销同一令牌时只有一个调用成功
+	_, err := redis.String(conn.Do("SET", revokedKeyPrefix+id, 1, "PX", ttl, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *RedisRevocations) IsRevoked(id string) (bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	return redis.Bool(conn.Do("EXISTS", revokedKeyPrefix+id))
+}
+
+func (r *RedisRevocations) RevokeUser(userID uint, before time.Time, ttl time.Duration) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", revokedUserKeyPrefix+strconv.FormatUint(uint64(userID), 10), before.Unix(), "EX", int64(ttl.Seconds()))
+	return err
+}
+
+func (r *RedisRevocations) UserRevokedBefore(userID uint) (time.Time, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	ts, err := redis.Int64(conn.Do("GET", revokedUserKeyPrefix+strconv.FormatUint(uint64(userID), 10)))
+	if err == redis.ErrNil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0), nil
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidToken = errors.New("令牌无效")
+	ErrTokenExpired = errors.New("令牌已过期")
+	ErrTokenRevoked = errors.New("令牌已吊销")
+	ErrNoSigningKey = errors.New("未配置令牌签名密钥")
+	ErrTokensOff    = errors.New("令牌认证未启用")
+
+	ErrAccountDisabled = errors.New("账号已被禁用")
+)
+
+// 令牌类型，访问令牌用于调用接口，刷新令牌只能用于换取新的令牌
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+const tokenIssuer = "library_manage_sys"
+
+// Key 一个 HMAC 签名密钥，ID 写入令牌头部的 kid，校验时据此选择密钥
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Claims 令牌载荷，sub 为用户 ID
+type Claims struct {
+	Role string `json:"role"`
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// UserID 解析 sub 中的用户 ID
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 32)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// Issuer 签发和校验令牌
+// keys[0] 用于签发新令牌，其余密钥只用于校验：轮换时把新密钥放在最前，旧密钥保留到其签发的令牌全部过期
+type Issuer struct {
+	keys       []Key
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// Tokens 全局令牌签发器，启动时按配置创建；未配置签名密钥时为空，令牌认证不可用
+var Tokens *Issuer
+
+func NewIssuer(keys []Key, accessTTL, refreshTTL time.Duration) (*Issuer, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	for _, k := range keys {
+		if k.ID == "" || len(k.Secret) == 0 {
+			return nil, ErrNoSigningKey
+		}
+	}
+	return &Issuer{keys: keys, accessTTL: accessTTL, refreshTTL: refreshTTL}, nil
+}
+
+// Issue 为用户签发一对访问令牌和刷新令牌
+func (i *Issuer) Issue(userID uint, role string, now time.Time) (*models.TokenPair, error) {
+	if i == nil {
+		return nil, ErrTokensOff
+	}
+	access, err := i.sign(userID, role, TokenTypeAccess, now, i.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := i.sign(userID, role, TokenTypeRefresh, now, i.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(i.accessTTL.Seconds()),
+		RefreshExpiresIn: int64(i.refreshTTL.Seconds()),
+	}, nil
+}
+
+func (i *Issuer) sign(userID uint, role, typ string, now time.Time, ttl time.Duration) (string, error) {
+	claims := Claims{
+		Role: role,
+		Type: typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    tokenIssuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = i.keys[0].ID
+	return token.SignedString(i.keys[0].Secret)
+}
+
+// Parse 校验签名、有效期、类型和吊销状态，返回令牌载荷
+func (i *Issuer) Parse(tokenString, typ string) (*Claims, error) {
+	if i == nil {
+		return nil, ErrTokensOff
+	}
+	var claims Claims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, i.lookupKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
+	}
+	if claims.Type != typ || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := Revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+	revoked, err = SessionRevoked(userID, claims.IssuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return &claims, nil
+}
+
+func (i *Issuer) lookupKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, k := range i.keys {
+		if k.ID == kid {
+			return k.Secret, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// Revoke 吊销令牌直到其过期，过期后令牌本身已失效，不必继续保留；重复吊销不报错
+func Revoke(claims *Claims) error {
+	if claims.ExpiresAt == nil {
+		return nil
+	}
+	_, err := Revocations.Revoke(claims.ID, claims.ExpiresAt.Time)
+	return err
+}
+
+// Consume 吊销只能使用一次的令牌（刷新令牌），令牌已被其他请求吊销时返回 ErrTokenRevoked
+// 检查和吊销是同一个原子操作，同一令牌并发使用时只有一个调用成功
+func Consume(claims *Claims) error {
+	if claims.ExpiresAt == nil {
+		return ErrInvalidToken
+	}
+	revoked, err := Revocations.Revoke(claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// sessionMaxAge 会话的有效期，与会话中间件的 Cookie 有效期一致
+const sessionMaxAge = 7 * 24 * time.Hour
+
+// RevokeUser 使用户在 now 之前签发的令牌和建立的会话全部失效，用于禁用账号、重置密码等场景
+// 记录保留到这些令牌和会话都已自然过期
+func RevokeUser(userID uint, now time.Time) error {
+	ttl := sessionMaxAge
+	if Tokens != nil && Tokens.refreshTTL > ttl {
+		ttl = Tokens.refreshTTL
+	}
+	return Revocations.RevokeUser(userID, now, ttl)
+}
+
+// SessionRevoked 判断用户在 issuedAt 建立的会话或签发的令牌是否已被 RevokeUser 吊销
+// 签发时间精确到秒，与 RevokeUser 同一秒内签发的令牌仍然有效，保证随后重新签发的令牌可用
+func SessionRevoked(userID uint, issuedAt time.Time) (bool, error) {
+	before, err := Revocations.UserRevokedBefore(userID)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.Before(before.Truncate(time.Second)), nil
+}
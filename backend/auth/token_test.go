@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestIssuer(t *testing.T) *Issuer {
+	t.Helper()
+
+	old := Revocations
+	Revocations = NewMemoryRevocations()
+	t.Cleanup(func() { Revocations = old })
+
+	issuer, err := NewIssuer([]Key{{ID: "k1", Secret: []byte("secret")}}, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issuer
+}
+
+func TestConsumeRefreshTokenOnce(t *testing.T) {
+	issuer := newTestIssuer(t)
+	pair, err := issuer.Issue(1, RoleUser, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一刷新令牌并发使用，只能有一个请求成功
+	const workers = 20
+	var ok, revoked atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claims, err := issuer.Parse(pair.RefreshToken, TokenTypeRefresh)
+			if err == nil {
+				err = Consume(claims)
+			}
+			switch {
+			case err == nil:
+				ok.Add(1)
+			case errors.Is(err, ErrTokenRevoked):
+				revoked.Add(1)
+			default:
+				t.Errorf("意外的错误: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ok.Load() != 1 || revoked.Load() != workers-1 {
+		t.Errorf("成功 %d 次、已吊销 %d 次，期望 1 次和 %d 次", ok.Load(), revoked.Load(), workers-1)
+	}
+	if _, err := issuer.Parse(pair.RefreshToken, TokenTypeRefresh); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("使用后再次校验返回 %v，期望 ErrTokenRevoked", err)
+	}
+}
+
+func TestRevokeIsIdempotent(t *testing.T) {
+	issuer := newTestIssuer(t)
+	pair, err := issuer.Issue(1, RoleUser, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.Parse(pair.AccessToken, TokenTypeAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Revoke(claims); err != nil {
+			t.Fatalf("第 %d 次吊销返回 %v", i+1, err)
+		}
+	}
+	if err := Consume(claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("已吊销的令牌 Consume 返回 %v，期望 ErrTokenRevoked", err)
+	}
+}
+
+func TestParseRejectsWrongType(t *testing.T) {
+	issuer := newTestIssuer(t)
+	pair, err := issuer.Issue(1, RoleUser, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Parse(pair.AccessToken, TokenTypeRefresh); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("访问令牌当作刷新令牌使用返回 %v，期望 ErrInvalidToken", err)
+	}
+
+	var disabled *Issuer
+	if _, err := disabled.Parse(pair.AccessToken, TokenTypeAccess); !errors.Is(err, ErrTokensOff) {
+		t.Errorf("未启用令牌认证时返回 %v，期望 ErrTokensOff", err)
+	}
+}
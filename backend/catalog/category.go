@@ -0,0 +1,341 @@
+package catalog
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("分类不存在")
+	ErrParentNotFound      = errors.New("上级分类不存在")
+	ErrCategoryCycle       = errors.New("不能将分类移动到自身或其下级分类下")
+	ErrCategoryHasChildren = errors.New("分类下还有子分类")
+	ErrCategoryCodeExists  = errors.New("分类号已存在")
+	ErrTagNotFound         = errors.New("标签不存在")
+	ErrTagExists           = errors.New("标签已存在")
+	ErrEmptyTag            = errors.New("标签不能为空")
+)
+
+// SaveCategory 新增（ID 为 0）或修改分类；上级分类变化时同步更新整棵子树的路径
+func SaveCategory(tx *gorm.DB, category *models.Category) error {
+	category.Code = strings.TrimSpace(category.Code)
+	category.Name = strings.TrimSpace(category.Name)
+
+	var exists int64
+	if err := tx.Model(&models.Category{}).
+		Where("code = ? AND id <> ?", category.Code, category.ID).
+		Count(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrCategoryCodeExists
+	}
+
+	parentPath := "/"
+	if category.ParentID != nil {
+		var parent models.Category
+		if err := tx.First(&parent, *category.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrParentNotFound
+			}
+			return err
+		}
+		parentPath = parent.Path
+	}
+
+	if category.ID == 0 {
+		if err := tx.Omit("Path").Create(category).Error; err != nil {
+			return err
+		}
+		category.Path = parentPath + strconv.FormatUint(uint64(category.ID), 10) + "/"
+		return tx.Model(category).Update("path", category.Path).Error
+	}
+
+	var current models.Category
+	if err := tx.First(&current, category.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	if strings.HasPrefix(parentPath, current.Path) {
+		return ErrCategoryCycle
+	}
+
+	category.Path = parentPath + strconv.FormatUint(uint64(category.ID), 10) + "/"
+	if err := tx.Model(&current).Updates(map[string]interface{}{
+		"code":      category.Code,
+		"name":      category.Name,
+		"parent_id": category.ParentID,
+		"path":      category.Path,
+	}).Error; err != nil {
+		return err
+	}
+
+	if category.Path != current.Path {
+		// 子分类路径的前缀替换为新路径
+		if err := tx.Model(&models.Category{}).
+			Where("path LIKE ? AND id <> ?", current.Path+"%", category.ID).
+			Update("path", gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", category.Path, len(current.Path)+1)).Error; err != nil {
+			return err
+		}
+	}
+	return tx.First(category, category.ID).Error
+}
+
+// DeleteCategory 删除没有子分类的分类，并解除与图书的关联
+func DeleteCategory(tx *gorm.DB, id uint) error {
+	var category models.Category
+	if err := tx.First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+
+	var children int64
+	if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrCategoryHasChildren
+	}
+
+	if err := tx.Exec("DELETE FROM book_categories WHERE category_id = ?", id).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&category).Error
+}
+
+// CategoryTree 将分类列表组装为树，同级按分类号排序
+func CategoryTree(categories []models.Category) []models.Category {
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Code < categories[j].Code
+	})
+
+	children := make(map[uint][]models.Category)
+	var roots []models.Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	if roots == nil {
+		return []models.Category{}
+	}
+	return attach(roots)
+}
+
+// SetBookCategories 替换图书的全部分类
+func SetBookCategories(tx *gorm.DB, book *models.Book, ids []uint) error {
+	categories := []models.Category{}
+	if len(ids) > 0 {
+		if err := tx.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+			return err
+		}
+		found := make(map[uint]bool, len(categories))
+		for _, c := range categories {
+			found[c.ID] = true
+		}
+		for _, id := range ids {
+			if !found[id] {
+				return ErrCategoryNotFound
+			}
+		}
+	}
+	return tx.Model(book).Association("Categories").Replace(categories)
+}
+
+// normalizeTag 去除首尾空白并合并连续空白
+func normalizeTag(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// SaveTag 新增（ID 为 0）或重命名标签
+func SaveTag(tx *gorm.DB, tag *models.Tag) error {
+	tag.Name = normalizeTag(tag.Name)
+	if tag.Name == "" {
+		return ErrEmptyTag
+	}
+
+	var exists int64
+	if err := tx.Model(&models.Tag{}).Where("name = ? AND id <> ?", tag.Name, tag.ID).Count(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrTagExists
+	}
+
+	if tag.ID == 0 {
+		return tx.Create(tag).Error
+	}
+	result := tx.Model(&models.Tag{ID: tag.ID}).Update("name", tag.Name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := tx.First(&models.Tag{}, tag.ID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTagNotFound
+		}
+	}
+	return tx.First(tag, tag.ID).Error
+}
+
+// DeleteTag 删除标签并解除与图书的关联
+func DeleteTag(tx *gorm.DB, id uint) error {
+	if err := tx.First(&models.Tag{}, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTagNotFound
+		}
+		return err
+	}
+	if err := tx.Exec("DELETE FROM book_tags WHERE tag_id = ?", id).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Tag{}, id).Error
+}
+
+// SetBookTags 按名称替换图书的全部标签，不存在的标签自动创建
+func SetBookTags(tx *gorm.DB, book *models.Book, names []string) error {
+	tags := []models.Tag{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = normalizeTag(name)
+		if name == "" {
+			return ErrEmptyTag
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var tag models.Tag
+		if err := tx.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+	return tx.Model(book).Association("Tags").Replace(tags)
+}
+
+// BooksInCategory 返回属于指定分类号（含其所有子分类）的图书 ID 子查询
+func BooksInCategory(db *gorm.DB, code string) *gorm.DB {
+	return db.Table("book_categories AS bc").
+		Select("bc.book_id").
+		Joins("JOIN categories AS sub ON sub.id = bc.category_id").
+		Joins("JOIN categories AS sel ON sub.path LIKE CONCAT(sel.path, '%')").
+		Where("sel.code = ?", code)
+}
+
+// BooksWithTag 返回带有指定标签的图书 ID 子查询
+func BooksWithTag(db *gorm.DB, name string) *gorm.DB {
+	return db.Table("book_tags AS bt").
+		Select("bt.book_id").
+		Joins("JOIN tags ON tags.id = bt.tag_id").
+		Where("tags.name = ?", normalizeTag(name))
+}
+
+// maxTagFacets 标签分面最多返回的条数，按图书数量从多到少
+const maxTagFacets = 50
+
+// Facets 统计 books（图书 ID 子查询）在分类和标签上的分布
+// parentCode 为当前所选分类号，分类分面返回其直接下级；为空时返回顶级分类
+func Facets(db *gorm.DB, books *gorm.DB, parentCode string) (*models.BookFacets, error) {
+	facets := &models.BookFacets{
+		Categories: []models.FacetCount{},
+		Tags:       []models.FacetCount{},
+	}
+
+	categories := db.Table("categories AS c").
+		Select("c.id, c.code, c.name, COUNT(DISTINCT bc.book_id) AS count").
+		Joins("JOIN categories AS sub ON sub.path LIKE CONCAT(c.path, '%')").
+		Joins("JOIN book_categories AS bc ON bc.category_id = sub.id").
+		Where("bc.book_id IN (?)", books).
+		Group("c.id, c.code, c.name").
+		Order("c.code")
+	if parentCode == "" {
+		categories = categories.Where("c.parent_id IS NULL")
+	} else {
+		categories = categories.Where("c.parent_id = (?)", db.Model(&models.Category{}).Select("id").Where("code = ?", parentCode))
+	}
+	if err := categories.Scan(&facets.Categories).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Table("tags AS t").
+		Select("t.id, t.name, COUNT(*) AS count").
+		Joins("JOIN book_tags AS bt ON bt.tag_id = t.id").
+		Where("bt.book_id IN (?)", books).
+		Group("t.id, t.name").
+		Order("count DESC, t.name").
+		Limit(maxTagFacets).
+		Scan(&facets.Tags).Error; err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// LoadClassification 为一批图书填充分类和标签，用于列表查询（分页查询不能带 Preload）
+func LoadClassification(db *gorm.DB, books []models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(books))
+	index := make(map[uint]int, len(books))
+	for i := range books {
+		ids[i] = books[i].ID
+		index[books[i].ID] = i
+	}
+
+	var categories []struct {
+		BookID uint
+		models.Category
+	}
+	if err := db.Table("book_categories AS bc").
+		Select("bc.book_id, categories.*").
+		Joins("JOIN categories ON categories.id = bc.category_id").
+		Where("bc.book_id IN ?", ids).
+		Order("categories.code").
+		Scan(&categories).Error; err != nil {
+		return err
+	}
+	for _, c := range categories {
+		b := &books[index[c.BookID]]
+		b.Categories = append(b.Categories, c.Category)
+	}
+
+	var tags []struct {
+		BookID uint
+		models.Tag
+	}
+	if err := db.Table("book_tags AS bt").
+		Select("bt.book_id, tags.*").
+		Joins("JOIN tags ON tags.id = bt.tag_id").
+		Where("bt.book_id IN ?", ids).
+		Order("tags.name").
+		Scan(&tags).Error; err != nil {
+		return err
+	}
+	for _, t := range tags {
+		b := &books[index[t.BookID]]
+		b.Tags = append(b.Tags, t.Tag)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package catalog
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/Dailiduzhou/library_manage_sys/images"
+	"github.com/Dailiduzhou/library_manage_sys/utils"
+)
+
+var (
+	ErrCoverNotFound = errors.New("压缩包中找不到封面文件")
+	ErrCoverType     = errors.New("封面仅支持 jpg、png、webp 格式")
+	ErrCoverTooLarge = errors.New("封面文件过大")
+)
+
+var coverExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+}
+
+// CoverArchive 随表格上传的封面压缩包，表格 cover 列填写压缩包内的文件名
+type CoverArchive struct {
+	files map[string]*zip.File
+}
+
+// OpenCoverArchive 读取 zip 压缩包的目录，封面可按完整路径或文件名查找
+func OpenCoverArchive(r io.ReaderAt, size int64) (*CoverArchive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &CoverArchive{files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		archive.files[f.Name] = f
+		if base := path.Base(f.Name); archive.files[base] == nil {
+			archive.files[base] = f
+		}
+	}
+	return archive, nil
+}
+
+// lookup 查找并校验封面文件
+func (a *CoverArchive) lookup(name string) (*zip.File, error) {
+	if a == nil {
+		return nil, ErrCoverNotFound
+	}
+
+	f, ok := a.files[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return nil, ErrCoverNotFound
+	}
+	if !coverExts[strings.ToLower(path.Ext(f.Name))] {
+		return nil, ErrCoverType
+	}
+	if limit := images.CoverLimits.MaxBytes; limit > 0 && f.UncompressedSize64 > uint64(limit) {
+		return nil, ErrCoverTooLarge
+	}
+	return f, nil
+}
+
+// saveCover 解压封面并保存到上传目录，返回封面路径
+func saveCover(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	// 目录中的大小可以伪造，保存时按实际内容再校验一次
+	return utils.SaveImageReader(rc)
+}
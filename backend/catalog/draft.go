@@ -0,0 +1,85 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMissingTitle  = errors.New("缺少书名")
+	ErrMissingAuthor = errors.New("缺少作者")
+	ErrInvalidStock  = errors.New("初始库存不能为负数")
+)
+
+// BookDraft 待入库的图书信息，来自表单、MARC 记录或表格行
+type BookDraft struct {
+	Title        string
+	Author       string
+	Summary      string
+	ISBN         string // Normalize 之后为规范化的 ISBN-13
+	CoverPath    string
+	CoverName    string // 批量导入时封面在压缩包中的文件名，入库时保存为 CoverPath
+	InitialStock int
+}
+
+// Normalize 去除首尾空白、校验必填项并把 ISBN 规范化为 ISBN-13
+func (d *BookDraft) Normalize() error {
+	d.Title = strings.TrimSpace(d.Title)
+	d.Author = strings.TrimSpace(d.Author)
+	d.Summary = strings.TrimSpace(d.Summary)
+	d.ISBN = strings.TrimSpace(d.ISBN)
+	d.CoverName = strings.TrimSpace(d.CoverName)
+
+	if d.Title == "" {
+		return ErrMissingTitle
+	}
+	if d.Author == "" {
+		return ErrMissingAuthor
+	}
+	if d.InitialStock < 0 {
+		return ErrInvalidStock
+	}
+
+	if d.ISBN != "" {
+		isbn13, err := NormalizeISBN(d.ISBN)
+		if err != nil {
+			return err
+		}
+		d.ISBN = isbn13
+	}
+	return nil
+}
+
+// Create 创建图书并按初始库存生成单册，需在事务中调用，调用前应已 Normalize 并完成判重
+func (d BookDraft) Create(tx *gorm.DB) (*models.Book, error) {
+	book := models.Book{
+		Title:        d.Title,
+		Author:       d.Author,
+		Summary:      d.Summary,
+		CoverPath:    d.CoverPath,
+		InitialStock: d.InitialStock,
+	}
+	if book.Summary == "" {
+		book.Summary = models.DefaultSummary
+	}
+	if book.CoverPath == "" {
+		book.CoverPath = models.DefaultCoverPath
+	}
+	SetISBN(&book, d.ISBN)
+	SetPinyin(&book)
+
+	if err := tx.Create(&book).Error; err != nil {
+		return nil, err
+	}
+	if _, err := jobs.CreateCopies(tx, book.ID, d.InitialStock, ""); err != nil {
+		return nil, err
+	}
+	if err := jobs.SyncBookStock(tx, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
@@ -0,0 +1,41 @@
+package catalog
+
+import (
+	"errors"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+// FindDuplicate 查找与待入库图书重复的已有图书，未找到时返回 nil
+// 提供 ISBN（规范化后的 ISBN-13）时只按 ISBN 判断，避免同名不同版本互相冲突；否则按书名和作者判断
+func FindDuplicate(db *gorm.DB, title, author, isbn13 string) (*models.Book, error) {
+	// 已归档的图书也算重复，应恢复原记录而不是重新入库；已彻底删除、仅为借阅历史保留的图书除外
+	query := db.Unscoped().Model(&models.Book{}).Where("purged_at IS NULL")
+	if isbn13 != "" {
+		query = query.Where("isbn13 = ?", isbn13)
+	} else {
+		query = query.Where("title = ? AND author = ?", title, author)
+	}
+
+	var existing models.Book
+	err := query.First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// SetISBN 按规范化的 ISBN-13 填写图书的 ISBN 字段，空字符串表示清除
+func SetISBN(book *models.Book, isbn13 string) {
+	if isbn13 == "" {
+		book.ISBN13 = nil
+		book.ISBN10 = ""
+		return
+	}
+	book.ISBN13 = &isbn13
+	book.ISBN10, _ = ISBN13To10(isbn13)
+}
@@ -0,0 +1,271 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+// 导出格式
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatMARCXML = "marcxml"
+)
+
+var ErrUnknownExportFormat = errors.New("不支持的导出格式")
+
+// ExportContentType 返回导出格式对应的 Content-Type 和文件扩展名
+func ExportContentType(format string) (contentType, ext string) {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8", ".csv"
+	case ExportFormatNDJSON:
+		return "application/x-ndjson; charset=utf-8", ".ndjson"
+	case ExportFormatMARCXML:
+		return "application/marcxml+xml; charset=utf-8", ".xml"
+	}
+	return "application/octet-stream", ""
+}
+
+// BookWriter 逐条写出图书，Flush 将已写内容推送到底层 Writer，Close 写出结尾并刷新缓冲
+type BookWriter interface {
+	WriteBook(book *models.Book) error
+	Flush() error
+	Close() error
+}
+
+// RecordWriter 逐条写出借阅记录，记录需预加载 User、Book 与 Copy
+type RecordWriter interface {
+	WriteRecord(record *models.BorrowRecord) error
+	Flush() error
+	Close() error
+}
+
+// NewBookWriter 创建图书导出器，支持 csv、ndjson、marcxml
+func NewBookWriter(w io.Writer, format string) (BookWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		// title、author、isbn、summary、initial_stock 与表格导入的列名一致，导出文件可再导入书目信息；
+		// cover_path 是封面存储中的路径，导入只识别随压缩包上传的 cover 列文件名，再导入的图书使用默认封面
+		cw, err := newCSVWriter(w, []string{
+			"id", "title", "author", "isbn", "summary", "initial_stock", "total_stock", "stock",
+			"cover_path", "created_at", "updated_at",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &csvBookWriter{cw}, nil
+	case ExportFormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case ExportFormatMARCXML:
+		return newMARCXMLWriter(w)
+	}
+	return nil, ErrUnknownExportFormat
+}
+
+// NewRecordWriter 创建借阅记录导出器，支持 csv、ndjson
+func NewRecordWriter(w io.Writer, format string) (RecordWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		cw, err := newCSVWriter(w, []string{
+			"id", "user_id", "username", "book_id", "title", "copy_id", "barcode", "status",
+			"borrow_date", "due_date", "return_date", "renew_count",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &csvRecordWriter{cw}, nil
+	case ExportFormatNDJSON:
+		return newNDJSONWriter(w), nil
+	}
+	return nil, ErrUnknownExportFormat
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, header []string) (*csvWriter, error) {
+	// 写入 UTF-8 BOM，Excel 打开时中文不乱码
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	return cw.Flush()
+}
+
+type csvBookWriter struct {
+	*csvWriter
+}
+
+func (cw *csvBookWriter) WriteBook(b *models.Book) error {
+	isbn := ""
+	if b.ISBN13 != nil {
+		isbn = *b.ISBN13
+	}
+	return cw.w.Write([]string{
+		strconv.FormatUint(uint64(b.ID), 10),
+		b.Title,
+		b.Author,
+		isbn,
+		b.Summary,
+		strconv.Itoa(b.InitialStock),
+		strconv.Itoa(b.TotalStock),
+		strconv.Itoa(b.Stock),
+		b.CoverPath,
+		formatTime(&b.CreatedAt),
+		formatTime(&b.UpdatedAt),
+	})
+}
+
+type csvRecordWriter struct {
+	*csvWriter
+}
+
+func (cw *csvRecordWriter) WriteRecord(r *models.BorrowRecord) error {
+	username, title, copyID, barcode := "", "", "", ""
+	if r.User != nil {
+		username = r.User.Username
+	}
+	if r.Book != nil {
+		title = r.Book.Title
+	}
+	if r.CopyID != nil {
+		copyID = strconv.FormatUint(uint64(*r.CopyID), 10)
+	}
+	if r.Copy != nil {
+		barcode = r.Copy.Barcode
+	}
+	return cw.w.Write([]string{
+		strconv.FormatUint(uint64(r.ID), 10),
+		strconv.FormatUint(uint64(r.UserID), 10),
+		username,
+		strconv.FormatUint(uint64(r.BookID), 10),
+		title,
+		copyID,
+		barcode,
+		r.Status,
+		formatTime(&r.BorrowDate),
+		formatTime(r.DueDate),
+		formatTime(r.ReturnDate),
+		strconv.Itoa(r.RenewCount),
+	})
+}
+
+// ndjsonWriter 每行一个 JSON 对象
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (nw *ndjsonWriter) WriteBook(b *models.Book) error {
+	return nw.enc.Encode(b)
+}
+
+func (nw *ndjsonWriter) WriteRecord(r *models.BorrowRecord) error {
+	return nw.enc.Encode(r)
+}
+
+func (nw *ndjsonWriter) Flush() error {
+	return nw.buf.Flush()
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.buf.Flush()
+}
+
+// marcXMLWriter 按 MARC21 slim 模式写出 collection，字段映射与导入一致
+type marcXMLWriter struct {
+	buf *bufio.Writer
+	enc *xml.Encoder
+}
+
+func newMARCXMLWriter(w io.Writer) (*marcXMLWriter, error) {
+	buf := bufio.NewWriter(w)
+	if _, err := buf.WriteString(xml.Header + `<collection xmlns="http://www.loc.gov/MARC21/slim">` + "\n"); err != nil {
+		return nil, err
+	}
+	return &marcXMLWriter{buf: buf, enc: xml.NewEncoder(buf)}, nil
+}
+
+func (mw *marcXMLWriter) WriteBook(b *models.Book) error {
+	record := marcXMLRecord{
+		Leader: "00000nam a2200000 i 4500",
+		ControlFields: []marcXMLControlField{
+			{Tag: "001", Value: strconv.FormatUint(uint64(b.ID), 10)},
+			{Tag: "005", Value: b.UpdatedAt.Format("20060102150405.0")},
+		},
+	}
+
+	if b.ISBN13 != nil {
+		record.DataFields = append(record.DataFields, marcXMLDataField{
+			Tag: "020", Ind1: " ", Ind2: " ",
+			Subfields: []marcXMLSubfield{{Code: "a", Value: *b.ISBN13}},
+		})
+	}
+	record.DataFields = append(record.DataFields,
+		marcXMLDataField{
+			Tag: "100", Ind1: "1", Ind2: " ",
+			Subfields: []marcXMLSubfield{{Code: "a", Value: b.Author}},
+		},
+		marcXMLDataField{
+			Tag: "245", Ind1: "1", Ind2: "0",
+			Subfields: []marcXMLSubfield{{Code: "a", Value: b.Title}},
+		},
+	)
+	if b.Summary != "" && b.Summary != models.DefaultSummary {
+		record.DataFields = append(record.DataFields, marcXMLDataField{
+			Tag: "520", Ind1: " ", Ind2: " ",
+			Subfields: []marcXMLSubfield{{Code: "a", Value: b.Summary}},
+		})
+	}
+
+	if err := mw.enc.Encode(record); err != nil {
+		return err
+	}
+	_, err := mw.buf.WriteString("\n")
+	return err
+}
+
+func (mw *marcXMLWriter) Flush() error {
+	return mw.buf.Flush()
+}
+
+func (mw *marcXMLWriter) Close() error {
+	if _, err := mw.buf.WriteString("</collection>\n"); err != nil {
+		return err
+	}
+	return mw.buf.Flush()
+}
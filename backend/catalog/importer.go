@@ -0,0 +1,309 @@
+package catalog
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"github.com/Dailiduzhou/library_manage_sys/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDuplicateBook = errors.New("图书已存在")
+	errImportAborted = errors.New("存在无效记录，已全部回滚")
+)
+
+// 单条导入结果
+const (
+	ImportStatusCreated   = "created"           // 已入库
+	ImportStatusValid     = "valid"             // 试运行：校验通过，提交后将入库
+	ImportStatusDuplicate = "skipped-duplicate" // 与馆藏或本批次中之前的记录重复，已跳过
+	ImportStatusInvalid   = "invalid"           // 数据不合法，已跳过
+)
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	DryRun       bool // 只校验和判重，不写入数据库
+	Atomic       bool // 所有记录在同一事务中提交，任一记录无效则全部回滚
+	InitialStock int  // 未单独指定时每种新书自动生成的单册数量
+}
+
+// ImportResult 单条记录的导入结果，Index 从 1 开始
+type ImportResult struct {
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	ISBN   string `json:"isbn,omitempty"`
+	Status string `json:"status"`
+	BookID uint   `json:"book_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport 批量导入报告
+type ImportReport struct {
+	DryRun     bool           `json:"dry_run"`
+	Atomic     bool           `json:"atomic"`
+	RolledBack bool           `json:"rolled_back"`
+	Total      int            `json:"total"`
+	Created    int            `json:"created"`
+	Valid      int            `json:"valid"`
+	Duplicates int            `json:"duplicates"`
+	Invalid    int            `json:"invalid"`
+	Results    []ImportResult `json:"results"`
+}
+
+func (r *ImportReport) add(result ImportResult) {
+	r.Total++
+	switch result.Status {
+	case ImportStatusCreated:
+		r.Created++
+	case ImportStatusValid:
+		r.Valid++
+	case ImportStatusDuplicate:
+		r.Duplicates++
+	case ImportStatusInvalid:
+		r.Invalid++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// rollback 整体回滚后，已入库的记录改为校验通过
+func (r *ImportReport) rollback() {
+	r.RolledBack = true
+	for i := range r.Results {
+		if r.Results[i].Status == ImportStatusCreated {
+			r.Results[i].Status = ImportStatusValid
+			r.Results[i].BookID = 0
+			r.Created--
+			r.Valid++
+		}
+	}
+}
+
+// Importer 逐条导入图书，每条记录单独提交（整体事务中为保存点），一条失败不影响其他记录
+type Importer struct {
+	db     *gorm.DB
+	opts   ImportOptions
+	covers *CoverArchive
+	seen   map[string]int
+	saved  []string
+	Report ImportReport
+}
+
+func newImporter(db *gorm.DB, opts ImportOptions, covers *CoverArchive) *Importer {
+	return &Importer{
+		db:     db,
+		opts:   opts,
+		covers: covers,
+		seen:   make(map[string]int),
+		Report: ImportReport{DryRun: opts.DryRun, Atomic: opts.Atomic, Results: []ImportResult{}},
+	}
+}
+
+// runImport 按选项执行导入；整体事务模式下有无效记录时回滚并删除已保存的封面
+func runImport(db *gorm.DB, opts ImportOptions, covers *CoverArchive, feed func(im *Importer) error) (*ImportReport, error) {
+	if opts.DryRun || !opts.Atomic {
+		im := newImporter(db, opts, covers)
+		err := feed(im)
+		syncCreated(db, &im.Report)
+		return &im.Report, err
+	}
+
+	var im *Importer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		im = newImporter(tx, opts, covers)
+		if err := feed(im); err != nil {
+			return err
+		}
+		if im.Report.Invalid > 0 {
+			return errImportAborted
+		}
+		return nil
+	})
+	if err != nil {
+		for _, p := range im.saved {
+			utils.RemoveFile(p)
+		}
+		im.Report.rollback()
+	}
+	if errors.Is(err, errImportAborted) {
+		return &im.Report, nil
+	}
+	if err == nil {
+		syncCreated(db, &im.Report)
+	}
+	return &im.Report, err
+}
+
+// syncCreated 导入提交后将新建的图书写入搜索索引
+func syncCreated(db *gorm.DB, report *ImportReport) {
+	var ids []uint
+	for _, r := range report.Results {
+		if r.Status == ImportStatusCreated {
+			ids = append(ids, r.BookID)
+		}
+	}
+	search.SyncBooks(db, ids...)
+}
+
+// dedupKey 与 FindDuplicate 的规则一致：有 ISBN 按 ISBN，否则按书名和作者
+func dedupKey(d BookDraft) string {
+	if d.ISBN != "" {
+		return "isbn:" + d.ISBN
+	}
+	return "ta:" + strings.ToLower(d.Title) + "\x00" + strings.ToLower(d.Author)
+}
+
+// AddInvalid 记录一条无法解析的记录
+func (im *Importer) AddInvalid(index int, draft BookDraft, err error) {
+	im.Report.add(ImportResult{
+		Index:  index,
+		Title:  strings.TrimSpace(draft.Title),
+		Author: strings.TrimSpace(draft.Author),
+		ISBN:   strings.TrimSpace(draft.ISBN),
+		Status: ImportStatusInvalid,
+		Error:  err.Error(),
+	})
+}
+
+// Add 校验、判重并（非试运行时）创建一条图书
+func (im *Importer) Add(index int, draft BookDraft) ImportResult {
+	result := ImportResult{
+		Index:  index,
+		Title:  strings.TrimSpace(draft.Title),
+		Author: strings.TrimSpace(draft.Author),
+		ISBN:   strings.TrimSpace(draft.ISBN),
+	}
+
+	if err := draft.Normalize(); err != nil {
+		result.Status = ImportStatusInvalid
+		result.Error = err.Error()
+		im.Report.add(result)
+		return result
+	}
+	result.ISBN = draft.ISBN
+
+	var cover *zip.File
+	if draft.CoverName != "" {
+		f, err := im.covers.lookup(draft.CoverName)
+		if err != nil {
+			result.Status = ImportStatusInvalid
+			result.Error = err.Error() + ": " + draft.CoverName
+			im.Report.add(result)
+			return result
+		}
+		cover = f
+	}
+
+	key := dedupKey(draft)
+	if first, ok := im.seen[key]; ok {
+		result.Status = ImportStatusDuplicate
+		result.Error = fmt.Sprintf("与本批次第 %d 条记录重复", first)
+		im.Report.add(result)
+		return result
+	}
+
+	var bookID uint
+	var archived bool
+	var coverPath string
+	err := im.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := FindDuplicate(tx, draft.Title, draft.Author, draft.ISBN)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			bookID = existing.ID
+			archived = existing.DeletedAt.Valid
+			return ErrDuplicateBook
+		}
+		if im.opts.DryRun {
+			return nil
+		}
+		if cover != nil {
+			path, err := saveCover(cover)
+			if err != nil {
+				return fmt.Errorf("封面保存失败: %w", err)
+			}
+			coverPath = path
+			draft.CoverPath = path
+		}
+		created, err := draft.Create(tx)
+		if err != nil {
+			return err
+		}
+		bookID = created.ID
+		return nil
+	})
+
+	if coverPath != "" {
+		if err != nil {
+			utils.RemoveFile(coverPath)
+		} else {
+			im.saved = append(im.saved, coverPath)
+		}
+	}
+
+	switch {
+	case errors.Is(err, ErrDuplicateBook):
+		result.Status = ImportStatusDuplicate
+		result.BookID = bookID
+		result.Error = "与馆藏图书重复"
+		if archived {
+			result.Error = "与已归档图书重复"
+		}
+	case err != nil:
+		result.Status = ImportStatusInvalid
+		result.Error = err.Error()
+	case im.opts.DryRun:
+		result.Status = ImportStatusValid
+		im.seen[key] = index
+	default:
+		result.Status = ImportStatusCreated
+		result.BookID = bookID
+		im.seen[key] = index
+	}
+
+	im.Report.add(result)
+	return result
+}
+
+// ImportMARC 读取全部 MARC 记录并逐条导入；格式错误的记录记为 invalid，读取失败时返回已处理部分的报告和错误
+func ImportMARC(db *gorm.DB, reader MARCReader, opts ImportOptions) (*ImportReport, error) {
+	return runImport(db, opts, nil, func(im *Importer) error {
+		for index := 1; ; index++ {
+			record, err := reader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				if errors.Is(err, ErrMalformedRecord) {
+					im.AddInvalid(index, BookDraft{}, err)
+					continue
+				}
+				return err
+			}
+
+			draft := record.Draft()
+			draft.InitialStock = opts.InitialStock
+			im.Add(index, draft)
+		}
+	})
+}
+
+// ImportRows 导入表格数据行，结果序号为表格行号；covers 为 nil 时填写了封面的行记为无效
+func ImportRows(db *gorm.DB, rows []SheetRow, covers *CoverArchive, opts ImportOptions) (*ImportReport, error) {
+	return runImport(db, opts, covers, func(im *Importer) error {
+		for _, row := range rows {
+			if row.Err != nil {
+				im.AddInvalid(row.Line, row.Draft, row.Err)
+				continue
+			}
+			im.Add(row.Line, row.Draft)
+		}
+		return nil
+	})
+}
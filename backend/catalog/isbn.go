@@ -0,0 +1,89 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidISBN = errors.New("ISBN 格式或校验位错误")
+
+// cleanISBN 去掉连字符和空格，并统一校验位 x 为大写
+func cleanISBN(raw string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		case r == '-' || r == ' ':
+		default:
+			// 其他字符保留，交给后续校验报错
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isbn10CheckDigit(first9 string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(first9[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+func isbn13CheckDigit(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(first12[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// NormalizeISBN 校验 ISBN-10 或 ISBN-13，统一返回不带连字符的 ISBN-13
+func NormalizeISBN(raw string) (string, error) {
+	s := cleanISBN(raw)
+
+	switch len(s) {
+	case 10:
+		if !isDigits(s[:9]) || isbn10CheckDigit(s[:9]) != s[9] {
+			return "", ErrInvalidISBN
+		}
+		body := "978" + s[:9]
+		return body + string(isbn13CheckDigit(body)), nil
+	case 13:
+		if !isDigits(s) || isbn13CheckDigit(s[:12]) != s[12] {
+			return "", ErrInvalidISBN
+		}
+		return s, nil
+	default:
+		return "", ErrInvalidISBN
+	}
+}
+
+// ISBN13To10 将 978 前缀的 ISBN-13 转为 ISBN-10，979 前缀没有对应的 ISBN-10
+func ISBN13To10(isbn13 string) (string, bool) {
+	if len(isbn13) != 13 || !strings.HasPrefix(isbn13, "978") || !isDigits(isbn13) {
+		return "", false
+	}
+	body := isbn13[3:12]
+	return body + string(isbn10CheckDigit(body)), true
+}
@@ -0,0 +1,64 @@
+package catalog
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "ISBN-13", raw: "9787020002207", want: "9787020002207"},
+		{name: "带连字符的 ISBN-13", raw: "978-0-306-40615-7", want: "9780306406157"},
+		{name: "带空格的 ISBN-13", raw: "978 7 02 000220 7", want: "9787020002207"},
+		{name: "ISBN-10", raw: "0306406152", want: "9780306406157"},
+		{name: "校验位为 X 的 ISBN-10", raw: "080442957X", want: "9780804429573"},
+		{name: "校验位为小写 x", raw: "0-8044-2957-x", want: "9780804429573"},
+		{name: "979 前缀", raw: "979-10-90636-07-1", want: "9791090636071"},
+		{name: "ISBN-13 校验位错误", raw: "9787020002208", wantErr: true},
+		{name: "ISBN-10 校验位错误", raw: "0306406153", wantErr: true},
+		{name: "ISBN-13 中出现 X", raw: "978702000220X", wantErr: true},
+		{name: "含字母", raw: "97870200022O7", wantErr: true},
+		{name: "带前缀文字", raw: "isbn 9780306406157", wantErr: true},
+		{name: "位数不足", raw: "978030640615", wantErr: true},
+		{name: "空值", raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeISBN(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Errorf("NormalizeISBN(%q) 返回 %q, %v，期望 ErrInvalidISBN", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeISBN(%q) 返回 %q, %v，期望 %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestISBN13To10(t *testing.T) {
+	tests := []struct {
+		isbn13 string
+		want   string
+		wantOK bool
+	}{
+		{isbn13: "9780306406157", want: "0306406152", wantOK: true},
+		{isbn13: "9780804429573", want: "080442957X", wantOK: true},
+		{isbn13: "9787020002207", want: "702000220X", wantOK: true},
+		{isbn13: "9791090636071"},
+		{isbn13: "978030640615"},
+	}
+	for _, tt := range tests {
+		got, ok := ISBN13To10(tt.isbn13)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ISBN13To10(%q) 返回 %q, %v，期望 %q, %v", tt.isbn13, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	marcRecordTerminator = 0x1D
+	marcFieldTerminator  = 0x1E
+	marcSubfieldDelim    = 0x1F
+	marcLeaderLen        = 24
+	marcDirEntryLen      = 12
+)
+
+var (
+	// ErrMalformedRecord 单条记录格式错误，读取器会跳过该记录继续读取后续记录
+	ErrMalformedRecord = errors.New("MARC 记录格式错误")
+	ErrUnknownFormat   = errors.New("不支持的 MARC 格式")
+)
+
+// MARC 文件格式
+const (
+	MARCFormatAuto    = "auto"
+	MARCFormatMARC21  = "marc21"
+	MARCFormatMARCXML = "marcxml"
+)
+
+// MARCSubfield 数据字段中的子字段，如 245$a
+type MARCSubfield struct {
+	Code  string
+	Value string
+}
+
+// MARCField 控制字段（00X）只有 Value，数据字段有指示符和子字段
+type MARCField struct {
+	Tag       string
+	Ind1      string
+	Ind2      string
+	Value     string
+	Subfields []MARCSubfield
+}
+
+// MARCRecord 一条书目记录
+type MARCRecord struct {
+	Leader string
+	Fields []MARCField
+}
+
+// SubfieldValues 返回所有指定字段中指定子字段的值
+func (r *MARCRecord) SubfieldValues(tag, code string) []string {
+	var values []string
+	for _, f := range r.Fields {
+		if f.Tag != tag {
+			continue
+		}
+		for _, sf := range f.Subfields {
+			if sf.Code == code {
+				values = append(values, sf.Value)
+			}
+		}
+	}
+	return values
+}
+
+// Subfield 返回第一个匹配的子字段值
+func (r *MARCRecord) Subfield(tag, code string) string {
+	if values := r.SubfieldValues(tag, code); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Draft 将 MARC 字段映射为待入库图书
+// 020$a -> ISBN，245$a$b -> 书名，100/110/111/700$a -> 作者，520$a -> 简介
+func (r *MARCRecord) Draft() BookDraft {
+	draft := BookDraft{
+		Summary: strings.TrimSpace(r.Subfield("520", "a")),
+	}
+
+	title := trimMARCPunct(r.Subfield("245", "a"))
+	if sub := trimMARCPunct(r.Subfield("245", "b")); sub != "" {
+		title += " : " + sub
+	}
+	draft.Title = title
+
+	for _, tag := range []string{"100", "110", "111", "700"} {
+		if author := trimMARCPunct(r.Subfield(tag, "a")); author != "" {
+			draft.Author = author
+			break
+		}
+	}
+	if draft.Author == "" {
+		draft.Author = trimMARCPunct(r.Subfield("245", "c"))
+	}
+
+	// 020$a 常带有装帧说明，如 "9787111213826 (pbk.)"，优先取第一个能通过校验的 ISBN
+	for _, raw := range r.SubfieldValues("020", "a") {
+		token := strings.Fields(raw)
+		if len(token) == 0 {
+			continue
+		}
+		if draft.ISBN == "" {
+			draft.ISBN = token[0]
+		}
+		if _, err := NormalizeISBN(token[0]); err == nil {
+			draft.ISBN = token[0]
+			break
+		}
+	}
+
+	return draft
+}
+
+// trimMARCPunct 去除编目时附加在字段末尾的 ISBD 标点
+func trimMARCPunct(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,.="))
+}
+
+// MARCReader 逐条读取 MARC 记录，读完返回 io.EOF
+// 返回包装了 ErrMalformedRecord 的错误时只影响当前记录，可继续调用 Next
+type MARCReader interface {
+	Next() (*MARCRecord, error)
+}
+
+// NewMARCReader 按格式创建读取器，auto 时根据首个非空白字符判断是否为 XML
+func NewMARCReader(r io.Reader, format string) (MARCReader, error) {
+	br := bufio.NewReader(r)
+
+	switch format {
+	case "", MARCFormatAuto:
+		for {
+			b, err := br.Peek(1)
+			if err != nil {
+				if err == io.EOF {
+					return NewMARC21Reader(br), nil
+				}
+				return nil, err
+			}
+			switch b[0] {
+			case ' ', '\t', '\r', '\n', 0xEF, 0xBB, 0xBF:
+				// 跳过空白和 UTF-8 BOM
+				br.ReadByte()
+				continue
+			case '<':
+				return NewMARCXMLReader(br), nil
+			}
+			return NewMARC21Reader(br), nil
+		}
+	case MARCFormatMARC21:
+		return NewMARC21Reader(br), nil
+	case MARCFormatMARCXML:
+		return NewMARCXMLReader(br), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+type marc21Reader struct {
+	r *bufio.Reader
+}
+
+// NewMARC21Reader 读取 ISO 2709 格式的 MARC21 二进制文件
+func NewMARC21Reader(r io.Reader) MARCReader {
+	return &marc21Reader{r: bufio.NewReader(r)}
+}
+
+func (m *marc21Reader) Next() (*MARCRecord, error) {
+	// 跳过记录之间的换行等空白
+	for {
+		b, err := m.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '\r' && b[0] != '\n' && b[0] != ' ' {
+			break
+		}
+		m.r.ReadByte()
+	}
+
+	// 按记录结束符切分，长度前缀有误时也不会吞掉下一条记录
+	data, err := m.r.ReadBytes(marcRecordTerminator)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: 文件末尾记录不完整", ErrMalformedRecord)
+		}
+		return nil, err
+	}
+
+	if len(data) < marcLeaderLen+2 {
+		return nil, fmt.Errorf("%w: 记录过短", ErrMalformedRecord)
+	}
+	length, err := strconv.Atoi(string(data[:5]))
+	if err != nil || length != len(data) {
+		return nil, fmt.Errorf("%w: 记录长度与实际内容不符", ErrMalformedRecord)
+	}
+
+	return parseMARC21(data)
+}
+
+func parseMARC21(data []byte) (*MARCRecord, error) {
+	leader := data[:marcLeaderLen]
+	base, err := strconv.Atoi(string(leader[12:17]))
+	if err != nil || base <= marcLeaderLen || base > len(data) {
+		return nil, fmt.Errorf("%w: 数据基地址无效", ErrMalformedRecord)
+	}
+
+	// 编码位为 'a' 表示 UTF-8，否则为 MARC-8，只接受其中的纯 ASCII 记录
+	if leader[9] == 'a' {
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("%w: 记录不是有效的 UTF-8", ErrMalformedRecord)
+		}
+	} else {
+		for _, b := range data {
+			if b >= 0x80 {
+				return nil, fmt.Errorf("%w: 不支持 MARC-8 编码，请转换为 UTF-8", ErrMalformedRecord)
+			}
+		}
+	}
+
+	directory := data[marcLeaderLen : base-1]
+	if len(directory)%marcDirEntryLen != 0 || data[base-1] != marcFieldTerminator {
+		return nil, fmt.Errorf("%w: 目录区格式错误", ErrMalformedRecord)
+	}
+
+	record := &MARCRecord{Leader: string(leader)}
+	body := data[base:]
+	for i := 0; i < len(directory); i += marcDirEntryLen {
+		entry := directory[i : i+marcDirEntryLen]
+		tag := string(entry[:3])
+		fieldLen, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || start+fieldLen > len(body) || fieldLen == 0 {
+			return nil, fmt.Errorf("%w: 字段 %s 目录项无效", ErrMalformedRecord, tag)
+		}
+
+		raw := body[start : start+fieldLen]
+		raw = bytes.TrimSuffix(raw, []byte{marcFieldTerminator})
+
+		field := MARCField{Tag: tag}
+		if strings.HasPrefix(tag, "00") {
+			field.Value = string(raw)
+		} else {
+			if len(raw) < 2 {
+				return nil, fmt.Errorf("%w: 字段 %s 缺少指示符", ErrMalformedRecord, tag)
+			}
+			field.Ind1 = string(raw[0])
+			field.Ind2 = string(raw[1])
+			for _, part := range bytes.Split(raw[2:], []byte{marcSubfieldDelim}) {
+				if len(part) == 0 {
+					continue
+				}
+				field.Subfields = append(field.Subfields, MARCSubfield{
+					Code:  string(part[0]),
+					Value: string(part[1:]),
+				})
+			}
+		}
+		record.Fields = append(record.Fields, field)
+	}
+
+	return record, nil
+}
+
+// MARCXML 的 record 元素，读取和导出共用
+type marcXMLRecord struct {
+	XMLName       xml.Name              `xml:"record"`
+	Leader        string                `xml:"leader"`
+	ControlFields []marcXMLControlField `xml:"controlfield"`
+	DataFields    []marcXMLDataField    `xml:"datafield"`
+}
+
+type marcXMLControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcXMLDataField struct {
+	Tag       string            `xml:"tag,attr"`
+	Ind1      string            `xml:"ind1,attr"`
+	Ind2      string            `xml:"ind2,attr"`
+	Subfields []marcXMLSubfield `xml:"subfield"`
+}
+
+type marcXMLSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcXMLReader struct {
+	dec *xml.Decoder
+}
+
+// NewMARCXMLReader 读取 MARCXML（http://www.loc.gov/MARC21/slim），支持 collection 包裹或单条 record
+func NewMARCXMLReader(r io.Reader) MARCReader {
+	return &marcXMLReader{dec: xml.NewDecoder(r)}
+}
+
+func (m *marcXMLReader) Next() (*MARCRecord, error) {
+	for {
+		tok, err := m.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var raw marcXMLRecord
+		if err := m.dec.DecodeElement(&raw, &start); err != nil {
+			// XML 语法错误后解码器无法继续定位下一条记录
+			return nil, err
+		}
+
+		record := &MARCRecord{Leader: raw.Leader}
+		for _, cf := range raw.ControlFields {
+			record.Fields = append(record.Fields, MARCField{Tag: cf.Tag, Value: cf.Value})
+		}
+		for _, df := range raw.DataFields {
+			if len(df.Tag) != 3 {
+				return nil, fmt.Errorf("%w: 字段标识 %q 无效", ErrMalformedRecord, df.Tag)
+			}
+			field := MARCField{Tag: df.Tag, Ind1: df.Ind1, Ind2: df.Ind2}
+			for _, sf := range df.Subfields {
+				field.Subfields = append(field.Subfields, MARCSubfield{Code: sf.Code, Value: sf.Value})
+			}
+			record.Fields = append(record.Fields, field)
+		}
+		return record, nil
+	}
+}
@@ -0,0 +1,214 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// buildMARC21 按 ISO 2709 拼出一条记录，enc 为头标第 9 位的编码标识
+func buildMARC21(enc byte, fields ...MARCField) []byte {
+	var dir, body bytes.Buffer
+	for _, f := range fields {
+		var data []byte
+		if strings.HasPrefix(f.Tag, "00") {
+			data = []byte(f.Value)
+		} else {
+			data = []byte(f.Ind1 + f.Ind2)
+			for _, sf := range f.Subfields {
+				data = append(data, marcSubfieldDelim)
+				data = append(data, sf.Code+sf.Value...)
+			}
+		}
+		data = append(data, marcFieldTerminator)
+		fmt.Fprintf(&dir, "%s%04d%05d", f.Tag, len(data), body.Len())
+		body.Write(data)
+	}
+	dir.WriteByte(marcFieldTerminator)
+
+	base := marcLeaderLen + dir.Len()
+	total := base + body.Len() + 1
+	record := []byte(fmt.Sprintf("%05dnam %c22%05d   4500", total, enc, base))
+	record = append(record, dir.Bytes()...)
+	record = append(record, body.Bytes()...)
+	return append(record, marcRecordTerminator)
+}
+
+// weichengFields 《围城》的书目字段，020$a 带装帧说明
+var weichengFields = []MARCField{
+	{Tag: "001", Value: "012345"},
+	{Tag: "020", Ind1: " ", Ind2: " ", Subfields: []MARCSubfield{{"a", "978-7-02-000220-7 (精装)"}}},
+	{Tag: "100", Ind1: "1", Ind2: " ", Subfields: []MARCSubfield{{"a", "钱锺书,"}}},
+	{Tag: "245", Ind1: "1", Ind2: "0", Subfields: []MARCSubfield{{"a", "围城 :"}, {"b", "长篇小说 /"}, {"c", "钱锺书著."}}},
+	{Tag: "520", Ind1: " ", Ind2: " ", Subfields: []MARCSubfield{{"a", " 讽刺小说 "}}},
+}
+
+var weichengDraft = BookDraft{Title: "围城 : 长篇小说", Author: "钱锺书", Summary: "讽刺小说", ISBN: "978-7-02-000220-7"}
+
+// readAll 读取全部记录，格式错误的记录记为 nil 并继续
+func readAll(t *testing.T, r MARCReader) ([]*MARCRecord, []error) {
+	t.Helper()
+
+	var records []*MARCRecord
+	var errs []error
+	for i := 0; i < 10; i++ {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, errs
+		}
+		if err != nil && !errors.Is(err, ErrMalformedRecord) {
+			t.Fatalf("读取第 %d 条记录失败: %v", i+1, err)
+		}
+		records = append(records, record)
+		errs = append(errs, err)
+	}
+	t.Fatal("读取器未返回 io.EOF")
+	return nil, nil
+}
+
+func TestMARC21Reader(t *testing.T) {
+	valid := buildMARC21('a', weichengFields...)
+
+	badLength := buildMARC21('a', weichengFields...)
+	copy(badLength, "00099")
+
+	marc8 := buildMARC21(' ', weichengFields...)
+
+	ascii := buildMARC21(' ',
+		MARCField{Tag: "245", Ind1: "0", Ind2: "0", Subfields: []MARCSubfield{{"a", "Fortress besieged /"}, {"c", "Qian Zhongshu."}}},
+	)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  []*BookDraft // nil 表示该条记录格式错误
+	}{
+		{name: "UTF-8 记录", input: valid, want: []*BookDraft{&weichengDraft}},
+		{name: "记录之间有换行", input: bytes.Join([][]byte{valid, valid}, []byte("\r\n")), want: []*BookDraft{&weichengDraft, &weichengDraft}},
+		{name: "长度错误的记录被跳过", input: append(append([]byte{}, badLength...), valid...), want: []*BookDraft{nil, &weichengDraft}},
+		{name: "MARC-8 中的非 ASCII 字符", input: marc8, want: []*BookDraft{nil}},
+		{name: "MARC-8 纯 ASCII", input: ascii, want: []*BookDraft{{Title: "Fortress besieged", Author: "Qian Zhongshu"}}},
+		{name: "文件末尾记录不完整", input: append(append([]byte{}, valid...), valid[:40]...), want: []*BookDraft{&weichengDraft, nil}},
+		{name: "过短的记录", input: []byte("00010nam\x1d"), want: []*BookDraft{nil}},
+		{name: "空文件"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, errs := readAll(t, NewMARC21Reader(bytes.NewReader(tt.input)))
+			if len(records) != len(tt.want) {
+				t.Fatalf("读取到 %d 条记录，期望 %d 条，错误: %v", len(records), len(tt.want), errs)
+			}
+			for i, want := range tt.want {
+				if want == nil {
+					if errs[i] == nil {
+						t.Errorf("第 %d 条记录应返回格式错误", i+1)
+					}
+					continue
+				}
+				if errs[i] != nil {
+					t.Errorf("第 %d 条记录返回错误: %v", i+1, errs[i])
+					continue
+				}
+				if got := records[i].Draft(); got != *want {
+					t.Errorf("第 %d 条记录映射为 %+v，期望 %+v", i+1, got, *want)
+				}
+			}
+		})
+	}
+}
+
+const weichengXML = `<record>
+    <leader>00000nam a2200000   4500</leader>
+    <controlfield tag="001">012345</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">978-7-02-000220-7 (精装)</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">钱锺书,</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0">
+      <subfield code="a">围城 :</subfield>
+      <subfield code="b">长篇小说 /</subfield>
+      <subfield code="c">钱锺书著.</subfield>
+    </datafield>
+    <datafield tag="520" ind1=" " ind2=" "><subfield code="a"> 讽刺小说 </subfield></datafield>
+  </record>`
+
+func TestMARCXMLReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []*BookDraft
+	}{
+		{name: "单条 record", input: weichengXML, want: []*BookDraft{&weichengDraft}},
+		{
+			name:  "collection 与命名空间",
+			input: `<?xml version="1.0" encoding="UTF-8"?><collection xmlns="http://www.loc.gov/MARC21/slim">` + weichengXML + weichengXML + `</collection>`,
+			want:  []*BookDraft{&weichengDraft, &weichengDraft},
+		},
+		{
+			name: "字段标识无效的记录被跳过",
+			input: `<collection><record><datafield tag="24" ind1="1" ind2="0"><subfield code="a">x</subfield></datafield></record>` +
+				weichengXML + `</collection>`,
+			want: []*BookDraft{nil, &weichengDraft},
+		},
+		{
+			name:  "无作者字段时取 245$c",
+			input: `<collection><record><datafield tag="245" ind1="0" ind2="0"><subfield code="a">无名 /</subfield><subfield code="c">佚名.</subfield></datafield></record></collection>`,
+			want:  []*BookDraft{{Title: "无名", Author: "佚名"}},
+		},
+		{name: "空 collection", input: `<collection></collection>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, errs := readAll(t, NewMARCXMLReader(strings.NewReader(tt.input)))
+			if len(records) != len(tt.want) {
+				t.Fatalf("读取到 %d 条记录，期望 %d 条，错误: %v", len(records), len(tt.want), errs)
+			}
+			for i, want := range tt.want {
+				if want == nil {
+					if errs[i] == nil {
+						t.Errorf("第 %d 条记录应返回格式错误", i+1)
+					}
+					continue
+				}
+				if errs[i] != nil {
+					t.Errorf("第 %d 条记录返回错误: %v", i+1, errs[i])
+					continue
+				}
+				if got := records[i].Draft(); got != *want {
+					t.Errorf("第 %d 条记录映射为 %+v，期望 %+v", i+1, got, *want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewMARCReaderAuto(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "MARC21", input: buildMARC21('a', weichengFields...)},
+		{name: "MARCXML", input: []byte(weichengXML)},
+		{name: "带 BOM 和空白的 MARCXML", input: []byte("\ufeff\n  " + weichengXML)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewMARCReader(bytes.NewReader(tt.input), MARCFormatAuto)
+			if err != nil {
+				t.Fatalf("创建读取器失败: %v", err)
+			}
+			record, err := r.Next()
+			if err != nil {
+				t.Fatalf("读取记录失败: %v", err)
+			}
+			if got := record.Draft(); got != weichengDraft {
+				t.Errorf("记录映射为 %+v，期望 %+v", got, weichengDraft)
+			}
+		})
+	}
+
+	if _, err := NewMARCReader(strings.NewReader(""), "unimarc"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("未知格式返回 %v，期望 ErrUnknownFormat", err)
+	}
+}
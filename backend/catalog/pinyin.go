@@ -0,0 +1,34 @@
+package catalog
+
+import (
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"gorm.io/gorm"
+)
+
+// SetPinyin 根据书名和作者填写图书的拼音字段
+func SetPinyin(book *models.Book) {
+	book.TitlePinyin, book.TitleInitials = search.ToPinyin(book.Title)
+	book.AuthorPinyin, book.AuthorInitials = search.ToPinyin(book.Author)
+}
+
+// BackfillPinyin 为新增拼音列之前入库的图书（拼音列为 NULL）补全拼音，启动时调用
+func BackfillPinyin(db *gorm.DB) error {
+	var batch []models.Book
+	return db.Where("title_pinyin IS NULL").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for i := range batch {
+				book := &batch[i]
+				SetPinyin(book)
+				if err := db.Model(book).UpdateColumns(map[string]interface{}{
+					"title_pinyin":    book.TitlePinyin,
+					"title_initials":  book.TitleInitials,
+					"author_pinyin":   book.AuthorPinyin,
+					"author_initials": book.AuthorInitials,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
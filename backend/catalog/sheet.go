@@ -0,0 +1,156 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// MaxSheetRows 单个表格最多导入的数据行数
+const MaxSheetRows = 5000
+
+var (
+	ErrUnknownSheetType = errors.New("仅支持 .csv 和 .xlsx 文件")
+	ErrEmptySheet       = errors.New("表格为空")
+	ErrMissingColumns   = errors.New("表头缺少 title 或 author 列")
+	ErrTooManyRows      = fmt.Errorf("单次最多导入 %d 行", MaxSheetRows)
+)
+
+// 表头别名，列名不区分大小写
+var sheetColumns = map[string]string{
+	"title":         "title",
+	"书名":            "title",
+	"author":        "author",
+	"作者":            "author",
+	"summary":       "summary",
+	"简介":            "summary",
+	"initial_stock": "initial_stock",
+	"stock":         "initial_stock",
+	"初始库存":          "initial_stock",
+	"isbn":          "isbn",
+	"cover":         "cover",
+	"封面":            "cover",
+}
+
+// SheetRow 表格中的一行数据，Line 为表格中的行号（表头为第 1 行）
+type SheetRow struct {
+	Line  int
+	Draft BookDraft
+	Err   error
+}
+
+// ReadBookSheet 按扩展名读取 CSV 或 XLSX（第一个工作表），首行为表头
+// initial_stock 为空的行使用 defaultStock
+func ReadBookSheet(r io.Reader, filename string, defaultStock int) ([]SheetRow, error) {
+	var records [][]string
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		records, err = readCSV(r)
+	case ".xlsx":
+		records, err = readXLSX(r)
+	default:
+		return nil, ErrUnknownSheetType
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrEmptySheet
+	}
+	if len(records)-1 > MaxSheetRows {
+		return nil, ErrTooManyRows
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := sheetColumns[name]; ok {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, ErrMissingColumns
+	}
+	if _, ok := columns["author"]; !ok {
+		return nil, ErrMissingColumns
+	}
+
+	rows := make([]SheetRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		cell := func(field string) string {
+			idx, ok := columns[field]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		if isBlankRecord(record) {
+			continue
+		}
+
+		row := SheetRow{
+			Line: i + 2,
+			Draft: BookDraft{
+				Title:        cell("title"),
+				Author:       cell("author"),
+				Summary:      cell("summary"),
+				ISBN:         cell("isbn"),
+				CoverName:    cell("cover"),
+				InitialStock: defaultStock,
+			},
+		}
+		if raw := cell("initial_stock"); raw != "" {
+			stock, err := strconv.Atoi(raw)
+			if err != nil || stock < 0 || stock > 100 {
+				row.Err = fmt.Errorf("initial_stock 须为 0-100 的整数: %q", raw)
+			} else {
+				row.Draft.InitialStock = stock
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	return reader.ReadAll()
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrEmptySheet
+	}
+	return f.GetRows(sheets[0])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
+)
+
+// runCommand 执行命令行子命令，返回进程退出码
+// 用法: library_server <command> [flags]，不带子命令时启动 HTTP 服务
+func runCommand(args []string) int {
+	switch args[0] {
+	case "import-marc":
+		return importMARCCommand(args[1:])
+	case "gc-covers":
+		return gcCoversCommand(args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n可用命令: import-marc, gc-covers\n", args[0])
+	return 2
+}
+
+func importMARCCommand(args []string) int {
+	fs := flag.NewFlagSet("import-marc", flag.ContinueOnError)
+	path := fs.String("file", "", "MARC 文件路径（必填）")
+	format := fs.String("format", catalog.MARCFormatAuto, "文件格式: auto/marc21/marcxml")
+	commit := fs.Bool("commit", false, "写入数据库，默认只试运行")
+	atomic := fs.Bool("atomic", false, "整批在同一事务中提交，任一记录无效则全部回滚")
+	stock := fs.Int("stock", 0, "每种新书生成的单册数量")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *path == "" {
+		fs.Usage()
+		return 2
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开文件失败: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	reader, err := catalog.NewMARCReader(file, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	config.ConnectDB()
+	// 服务端使用 Redis 联想索引时，导入后需要使其失效；进程内索引在服务端重启后自然重建
+	if *commit && config.ConnectRedis() == nil {
+		search.Suggestions = search.NewRedisSuggester(config.DB, config.Redis)
+	}
+	report, err := catalog.ImportMARC(config.DB, reader, catalog.ImportOptions{
+		DryRun:       !*commit,
+		Atomic:       *atomic,
+		InitialStock: *stock,
+	})
+	printReport(report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "文件解析中断: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// gcCoversCommand 清理没有图书引用的封面文件，默认只列出不删除
+func gcCoversCommand(args []string) int {
+	fs := flag.NewFlagSet("gc-covers", flag.ContinueOnError)
+	grace := fs.Duration("grace", config.CoverGCGrace(), "宽限期，修改时间在此之内的文件不处理")
+	commit := fs.Bool("commit", false, "删除孤立文件，默认只试运行")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	config.ConnectDB()
+	store, err := storage.Open(config.StorageConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "封面存储初始化失败: %v\n", err)
+		return 1
+	}
+
+	report, err := jobs.CollectOrphanCovers(context.Background(), config.DB, store, time.Now(), *grace, !*commit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "封面清理失败: %v\n", err)
+		return 1
+	}
+	var size int64
+	for _, obj := range report.Orphans {
+		line, _ := json.Marshal(obj)
+		fmt.Println(string(line))
+		size += obj.Size
+	}
+	fmt.Fprintf(os.Stderr, "扫描 %d 个文件: 孤立 %d 个（%d KB）, 宽限期内 %d 个, 已删除 %d 个 (试运行: %v)\n",
+		report.Scanned, len(report.Orphans), size>>10, report.Recent, report.Deleted, report.DryRun)
+	if !report.DryRun && report.Deleted < len(report.Orphans) {
+		return 1
+	}
+	return 0
+}
+
+func printReport(report *catalog.ImportReport) {
+	if report == nil {
+		return
+	}
+	for _, r := range report.Results {
+		line, _ := json.Marshal(r)
+		fmt.Println(string(line))
+	}
+	fmt.Fprintf(os.Stderr, "共 %d 条: 新增 %d, 校验通过 %d, 重复 %d, 无效 %d (试运行: %v, 已回滚: %v)\n",
+		report.Total, report.Created, report.Valid, report.Duplicates, report.Invalid, report.DryRun, report.RolledBack)
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+)
+
+// devTokenSecret 开发环境（GIN_MODE=debug）未配置 JWT_KEYS 时使用的密钥；该密钥随源码公开，任何人都能用它伪造令牌
+const devTokenSecret = "5d0d6e1f3f2a4c8b9e7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e"
+
+var ErrNoTokenKeys = errors.New("未配置 JWT_KEYS")
+
+// TokenKeys 令牌签名密钥，JWT_KEYS 格式为 kid:secret,kid:secret
+// 第一个密钥用于签发，其余只用于校验；轮换时把新密钥加在最前，旧密钥在访问令牌和刷新令牌都过期后再移除
+// 未配置时返回 ErrNoTokenKeys，只有显式设置 GIN_MODE=debug 时才使用开发密钥
+func TokenKeys() ([]auth.Key, error) {
+	value := getEnv("JWT_KEYS", "")
+	if value == "" {
+		if os.Getenv("GIN_MODE") != "debug" {
+			return nil, ErrNoTokenKeys
+		}
+		log.Println("警告: 未配置 JWT_KEYS，使用随源码公开的开发密钥签发令牌，切勿用于生产环境")
+		return []auth.Key{{ID: "dev", Secret: []byte(devTokenSecret)}}, nil
+	}
+
+	var keys []auth.Key
+	for i, item := range strings.Split(value, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || id == "" || secret == "" {
+			log.Printf("JWT_KEYS 中第 %d 个密钥格式错误，已忽略", i+1)
+			continue
+		}
+		keys = append(keys, auth.Key{ID: id, Secret: []byte(secret)})
+	}
+	if len(keys) == 0 {
+		return nil, auth.ErrNoSigningKey
+	}
+	return keys, nil
+}
+
+// AccessTokenTTL 访问令牌有效期
+func AccessTokenTTL() time.Duration {
+	return getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL 刷新令牌有效期，与会话有效期一致
+func RefreshTokenTTL() time.Duration {
+	return getEnvDuration("JWT_REFRESH_TTL", 7*24*time.Hour)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+)
+
+func TestTokenKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		ginMode string
+		wantIDs []string
+		wantErr error
+	}{
+		{name: "未配置时禁用", wantErr: ErrNoTokenKeys},
+		{name: "release 模式未配置时禁用", ginMode: "release", wantErr: ErrNoTokenKeys},
+		{name: "debug 模式使用开发密钥", ginMode: "debug", wantIDs: []string{"dev"}},
+		{name: "多个密钥按顺序", keys: "k2:secret2, k1:secret1", wantIDs: []string{"k2", "k1"}},
+		{name: "忽略格式错误的密钥", keys: "bad,k1:secret1,:x,k2:", wantIDs: []string{"k1"}},
+		{name: "全部格式错误", keys: "bad,k1:", wantErr: auth.ErrNoSigningKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_KEYS", tt.keys)
+			t.Setenv("GIN_MODE", tt.ginMode)
+
+			keys, err := TokenKeys()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("返回错误 %v，期望 %v", err, tt.wantErr)
+			}
+			if len(keys) != len(tt.wantIDs) {
+				t.Fatalf("返回 %d 个密钥，期望 %v", len(keys), tt.wantIDs)
+			}
+			for i, id := range tt.wantIDs {
+				if keys[i].ID != id {
+					t.Errorf("第 %d 个密钥为 %q，期望 %q", i+1, keys[i].ID, id)
+				}
+			}
+		})
+	}
+}
@@ -69,12 +69,54 @@ func ConnectDB() {
 
 	log.Println("数据库连接成功!")
 
-	err = DB.AutoMigrate(&models.Book{}, &models.User{}, &models.BorrowRecord{})
-	if err != nil {
+	if err = Migrate(DB); err != nil {
 		log.Fatal("数据迁移失败", err)
 	}
 }
 
+// Migrate 创建或更新全部数据表，并补全旧数据
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.Book{}, &models.Copy{}, &models.User{}, &models.BorrowRecord{}, &models.Renewal{}, &models.Reservation{},
+		&models.Fee{}, &models.FeePayment{}, &models.Category{}, &models.Tag{}, &models.APIKey{}, &models.Role{}, &models.Permission{}); err != nil {
+		return err
+	}
+	return backfillDueDates(db)
+}
+
+// backfillDueDates 为引入应还时间之前借出、仍未归还的记录按借阅人角色的借期补上应还时间，
+// 否则这些记录永远不会被标记为逾期。已有应还时间的记录不受影响，重复执行是安全的
+func backfillDueDates(db *gorm.DB) error {
+	var records []models.BorrowRecord
+	var filled int64
+	result := db.Preload("User", func(tx *gorm.DB) *gorm.DB {
+		return tx.Select("id", "role")
+	}).Where("status = ? AND due_date IS NULL", models.BorrowStatusBorrowed).
+		FindInBatches(&records, 500, func(tx *gorm.DB, batch int) error {
+			for _, record := range records {
+				role := ""
+				if record.User != nil {
+					role = record.User.Role
+				}
+				dueDate := GetPolicy(role).DueDate(record.BorrowDate)
+				result := db.Model(&models.BorrowRecord{}).
+					Where("id = ? AND due_date IS NULL", record.ID).
+					UpdateColumn("due_date", dueDate)
+				if result.Error != nil {
+					return result.Error
+				}
+				filled += result.RowsAffected
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if filled > 0 {
+		log.Printf("为 %d 条未归还的旧借阅记录补全了应还时间", filled)
+	}
+	return nil
+}
+
 func InitAdmin(db *gorm.DB) {
 
 	adminUser := os.Getenv("ADMIN_USERNAME")
@@ -112,3 +154,8 @@ func InitAdmin(db *gorm.DB) {
 
 	log.Printf("成功创建默认管理员: %s / %s", adminUser, adminPass)
 }
+
+// SearchBackend 全文检索实现，见 search 包
+func SearchBackend() string {
+	return getEnv("SEARCH_BACKEND", "mysql")
+}
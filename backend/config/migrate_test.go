@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMigrateBackfillsDueDates(t *testing.T) {
+	t.Setenv("LOAN_DAYS", "")
+	t.Setenv("LOAN_DAYS_USER", "")
+	t.Setenv("LOAN_DAYS_ADMIN", "")
+
+	db, err := gorm.Open(sqlite.Open("file:migrate_backfill?mode=memory&cache=shared&_pragma=foreign_keys(1)"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := Migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	reader := models.User{Username: "reader", Password: "x", Role: "user"}
+	admin := models.User{Username: "admin", Password: "x", Role: "admin"}
+	book := models.Book{Title: "围城", Author: "钱锺书", CoverPath: models.DefaultCoverPath}
+	for _, v := range []interface{}{&reader, &admin, &book} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	borrowed := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	existing := borrowed.AddDate(0, 0, 7)
+	returned := borrowed.AddDate(0, 0, 3)
+	tests := []struct {
+		name   string
+		record models.BorrowRecord
+		want   *time.Time
+	}{
+		{name: "读者的旧借阅按读者借期补全", record: models.BorrowRecord{UserID: reader.ID, Status: models.BorrowStatusBorrowed}, want: ptrTime(borrowed.AddDate(0, 0, 30))},
+		{name: "管理员的旧借阅按管理员借期补全", record: models.BorrowRecord{UserID: admin.ID, Status: models.BorrowStatusBorrowed}, want: ptrTime(borrowed.AddDate(0, 0, 60))},
+		{name: "已有应还时间的不变", record: models.BorrowRecord{UserID: reader.ID, Status: models.BorrowStatusBorrowed, DueDate: &existing}, want: &existing},
+		{name: "已归还的不补全", record: models.BorrowRecord{UserID: reader.ID, Status: models.BorrowStatusReturned, ReturnDate: &returned}},
+	}
+	for i := range tests {
+		tests[i].record.BookID = book.ID
+		tests[i].record.BorrowDate = borrowed
+		if err := db.Create(&tests[i].record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重复执行不应改变已补全的结果
+	for i := 0; i < 2; i++ {
+		if err := Migrate(db); err != nil {
+			t.Fatalf("第 %d 次迁移失败: %v", i+1, err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.BorrowRecord
+			if err := db.First(&got, tt.record.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == nil && got.DueDate != nil:
+				t.Errorf("应还时间为 %v，期望为空", *got.DueDate)
+			case tt.want != nil && (got.DueDate == nil || !got.DueDate.Equal(*tt.want)):
+				t.Errorf("应还时间为 %v，期望 %v", got.DueDate, *tt.want)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CirculationPolicy 流通策略，按用户角色区分
+type CirculationPolicy struct {
+	LoanDays    int // 借期（天），续借时同样顺延该天数
+	MaxRenewals int // 最多续借次数
+
+	FinePerDay    int // 逾期每天罚金（分）
+	FineCap       int // 单次借阅罚金上限（分），0 表示不设上限
+	FineGraceDays int // 逾期宽限天数，宽限期内不计罚金
+
+	MaxActiveLoans    int  // 同时在借的最大册数
+	MaxCopiesPerTitle int  // 同一种书同时在借的最大册数
+	BlockOnOverdue    bool // 有逾期未还图书时禁止借书
+}
+
+// 各角色的默认流通策略，可通过环境变量覆盖，例如 LOAN_DAYS_USER=14
+var defaultPolicies = map[string]CirculationPolicy{
+	"user": {
+		LoanDays: 30, MaxRenewals: 2,
+		FinePerDay: 50, FineCap: 5000, FineGraceDays: 1,
+		MaxActiveLoans: 5, MaxCopiesPerTitle: 1, BlockOnOverdue: true,
+	},
+	"admin": {
+		LoanDays: 60, MaxRenewals: 5,
+		FinePerDay: 0, FineCap: 0, FineGraceDays: 0,
+		MaxActiveLoans: 20, MaxCopiesPerTitle: 2, BlockOnOverdue: false,
+	},
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("环境变量 %s 不是合法整数(%q)，使用默认值 %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("环境变量 %s 不是合法布尔值(%q)，使用默认值 %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("环境变量 %s 不是合法时长(%q)，使用默认值 %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func policyKey(name, role string) string {
+	return fmt.Sprintf("%s_%s", name, strings.ToUpper(role))
+}
+
+// GetPolicy 返回指定角色的流通策略，未知角色按普通用户处理
+func GetPolicy(role string) CirculationPolicy {
+	policy, ok := defaultPolicies[role]
+	if !ok {
+		policy = defaultPolicies["user"]
+	}
+
+	policy.LoanDays = getEnvInt(policyKey("LOAN_DAYS", role), getEnvInt("LOAN_DAYS", policy.LoanDays))
+	if policy.LoanDays <= 0 {
+		policy.LoanDays = defaultPolicies["user"].LoanDays
+	}
+	policy.MaxRenewals = getEnvInt(policyKey("MAX_RENEWALS", role), getEnvInt("MAX_RENEWALS", policy.MaxRenewals))
+	policy.FinePerDay = getEnvInt(policyKey("FINE_PER_DAY", role), getEnvInt("FINE_PER_DAY", policy.FinePerDay))
+	policy.FineCap = getEnvInt(policyKey("FINE_CAP", role), getEnvInt("FINE_CAP", policy.FineCap))
+	policy.FineGraceDays = getEnvInt(policyKey("FINE_GRACE_DAYS", role), getEnvInt("FINE_GRACE_DAYS", policy.FineGraceDays))
+	policy.MaxActiveLoans = getEnvInt(policyKey("MAX_ACTIVE_LOANS", role), getEnvInt("MAX_ACTIVE_LOANS", policy.MaxActiveLoans))
+	policy.MaxCopiesPerTitle = getEnvInt(policyKey("MAX_COPIES_PER_TITLE", role), getEnvInt("MAX_COPIES_PER_TITLE", policy.MaxCopiesPerTitle))
+	policy.BlockOnOverdue = getEnvBool(policyKey("BLOCK_ON_OVERDUE", role), getEnvBool("BLOCK_ON_OVERDUE", policy.BlockOnOverdue))
+
+	return policy
+}
+
+// DueDate 根据借出时间计算应还时间
+func (p CirculationPolicy) DueDate(from time.Time) time.Time {
+	return from.AddDate(0, 0, p.LoanDays)
+}
+
+// OverdueFine 计算从应还时间到 end 的逾期罚金（分），不足一天按一天计
+func (p CirculationPolicy) OverdueFine(dueDate, end time.Time) int64 {
+	if !end.After(dueDate) || p.FinePerDay <= 0 {
+		return 0
+	}
+
+	days := int((end.Sub(dueDate) + 24*time.Hour - 1) / (24 * time.Hour))
+	days -= p.FineGraceDays
+	if days <= 0 {
+		return 0
+	}
+
+	fine := int64(days) * int64(p.FinePerDay)
+	if p.FineCap > 0 && fine > int64(p.FineCap) {
+		fine = int64(p.FineCap)
+	}
+	return fine
+}
+
+// FineBlockThreshold 未结清费用超过该金额（分）时禁止借书
+func FineBlockThreshold() int64 {
+	return int64(getEnvInt("FINE_BLOCK_THRESHOLD", 1000))
+}
+
+// SweepInterval 后台扫描任务（逾期标记、罚金计算、预约过期）的执行间隔
+func SweepInterval() time.Duration {
+	return getEnvDuration("SWEEP_INTERVAL", 10*time.Minute)
+}
+
+// HoldPickupWindow 归还的图书为预约读者保留的时长
+func HoldPickupWindow() time.Duration {
+	return getEnvDuration("HOLD_PICKUP_WINDOW", 72*time.Hour)
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverdueFine(t *testing.T) {
+	due := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		policy CirculationPolicy
+		end    time.Time
+		want   int64
+	}{
+		{name: "未逾期", policy: CirculationPolicy{FinePerDay: 50}, end: due.Add(-time.Hour), want: 0},
+		{name: "恰好到期", policy: CirculationPolicy{FinePerDay: 50}, end: due, want: 0},
+		{name: "不足一天按一天计", policy: CirculationPolicy{FinePerDay: 50}, end: due.Add(time.Minute), want: 50},
+		{name: "整三天", policy: CirculationPolicy{FinePerDay: 50}, end: due.Add(3 * day), want: 150},
+		{name: "三天零一秒", policy: CirculationPolicy{FinePerDay: 50}, end: due.Add(3*day + time.Second), want: 200},
+		{name: "宽限期内", policy: CirculationPolicy{FinePerDay: 50, FineGraceDays: 2}, end: due.Add(2 * day), want: 0},
+		{name: "扣除宽限天数", policy: CirculationPolicy{FinePerDay: 50, FineGraceDays: 2}, end: due.Add(5 * day), want: 150},
+		{name: "达到上限", policy: CirculationPolicy{FinePerDay: 50, FineCap: 120}, end: due.Add(10 * day), want: 120},
+		{name: "未达上限", policy: CirculationPolicy{FinePerDay: 50, FineCap: 120}, end: due.Add(2 * day), want: 100},
+		{name: "宽限与上限", policy: CirculationPolicy{FinePerDay: 50, FineGraceDays: 1, FineCap: 120}, end: due.Add(3 * day), want: 100},
+		{name: "不计罚金", policy: CirculationPolicy{}, end: due.Add(10 * day), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.OverdueFine(due, tt.end); got != tt.want {
+				t.Errorf("OverdueFine 返回 %d，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
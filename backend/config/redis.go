@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis 业务缓存使用的连接池，Redis 不可用时为 nil，调用方需回退到进程内实现
+var Redis *redis.Pool
+
+// ConnectRedis 按 REDIS_HOST、REDIS_PORT、REDIS_PASSWORD、REDIS_DB 创建连接池并检查连通性
+func ConnectRedis() error {
+	addr := fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))
+	password := getEnv("REDIS_PASSWORD", "")
+	db, err := strconv.Atoi(getEnv("REDIS_DB", "0"))
+	if err != nil {
+		db = 0
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr,
+				redis.DialPassword(password),
+				redis.DialDatabase(db),
+				redis.DialConnectTimeout(3*time.Second),
+			)
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		pool.Close()
+		return fmt.Errorf("连接 Redis 失败: %w", err)
+	}
+
+	Redis = pool
+	log.Println("Redis 连接池初始化成功")
+	return nil
+}
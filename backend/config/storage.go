@@ -0,0 +1,53 @@
+package config
+
+import (
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/images"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
+)
+
+// StorageConfig 封面存储配置：STORAGE_BACKEND 为 local（默认）或 s3，s3 时读取 S3_* 变量
+func StorageConfig() storage.Config {
+	return storage.Config{
+		Backend:      getEnv("STORAGE_BACKEND", storage.BackendLocal),
+		LocalRoot:    getEnv("STORAGE_LOCAL_ROOT", "."),
+		LocalBaseURL: getEnv("STORAGE_LOCAL_BASE_URL", "/"),
+		S3Endpoint:   getEnv("S3_ENDPOINT", ""),
+		S3Region:     getEnv("S3_REGION", ""),
+		S3Bucket:     getEnv("S3_BUCKET", "library-covers"),
+		S3AccessKey:  getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:  getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:     getEnvBool("S3_USE_SSL", false),
+		S3PublicURL:  getEnv("S3_PUBLIC_URL", ""),
+	}
+}
+
+// CoverLimits 封面上传限制：COVER_MAX_BYTES 文件大小（字节），COVER_MAX_WIDTH、COVER_MAX_HEIGHT 尺寸（像素）
+func CoverLimits() images.Limits {
+	return images.Limits{
+		MaxBytes:  int64(getEnvInt("COVER_MAX_BYTES", int(images.CoverLimits.MaxBytes))),
+		MaxWidth:  getEnvInt("COVER_MAX_WIDTH", images.CoverLimits.MaxWidth),
+		MaxHeight: getEnvInt("COVER_MAX_HEIGHT", images.CoverLimits.MaxHeight),
+	}
+}
+
+// CoverGCEnabled 是否在服务进程中定期清理孤立封面，多实例共享存储时只需一个实例开启
+func CoverGCEnabled() bool {
+	return getEnvBool("COVER_GC_ENABLED", true)
+}
+
+// CoverGCInterval 孤立封面清理任务的执行间隔
+func CoverGCInterval() time.Duration {
+	return getEnvDuration("COVER_GC_INTERVAL", 24*time.Hour)
+}
+
+// CoverGCGrace 封面上传后的宽限期，期间即使未被引用也不清理
+func CoverGCGrace() time.Duration {
+	return getEnvDuration("COVER_GC_GRACE", 24*time.Hour)
+}
+
+// CoverGCDryRun 为 true 时定期清理只记录日志，不删除文件
+func CoverGCDryRun() bool {
+	return getEnvBool("COVER_GC_DRY_RUN", false)
+}
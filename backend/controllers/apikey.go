@@ -0,0 +1,409 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API Key 不存在")
+	ErrUserNotFound   = errors.New("用户不存在")
+	ErrExpiresInPast  = errors.New("过期时间必须晚于当前时间")
+)
+
+// createAPIKey 为用户生成并保存 API Key，权限范围按用户角色校验
+func createAPIKey(user *models.User, req *models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error) {
+	if err := auth.ValidateScopes(config.DB, req.Scopes, user.Role); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrExpiresInPast
+	}
+
+	raw, key, err := auth.NewAPIKey(user.ID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.DB.Create(key).Error; err != nil {
+		return nil, err
+	}
+	return &models.CreatedAPIKey{APIKey: *key, Key: raw}, nil
+}
+
+// revokeAPIKey 吊销 API Key，userID 不为 0 时只能吊销该用户自己的 Key；重复吊销视为成功
+func revokeAPIKey(id string, userID uint) (*models.APIKey, error) {
+	query := config.DB.Where("id = ?", id)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var key models.APIKey
+	if err := query.First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if err := config.DB.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &now
+	}
+	return &key, nil
+}
+
+// respondAPIKeyError 将 API Key 相关错误映射为 HTTP 响应
+func respondAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnknownScope), errors.Is(err, auth.ErrAdminScope), errors.Is(err, ErrExpiresInPast):
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, ErrAPIKeyNotFound), errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.Response{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "API Key 操作失败",
+		})
+	}
+}
+
+// @Summary 查询权限范围
+// @Description 列出创建 API Key 时可选的权限范围及说明
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=map[string]string} "查询成功"
+// @Router /api/api-keys/scopes [get]
+func GetAPIKeyScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: auth.Scopes,
+	})
+}
+
+// @Summary 查询我的 API Key
+// @Description 列出当前用户的 API Key（不含明文），含已吊销和已过期的 Key
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.APIKey} "查询成功"
+// @Failure 403 {object} models.Response "不能使用 API Key 访问"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/api-keys [get]
+func GetMyAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := config.DB.Where("user_id = ?", c.GetUint("user_id")).Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: keys,
+	})
+}
+
+// @Summary 创建 API Key
+// @Description 为当前用户创建 API Key，请求时放在 X-API-Key 请求头中；明文只在本次响应中返回，请妥善保存
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateAPIKeyRequest true "名称、权限范围和过期时间"
+// @Success 200 {object} models.Response{data=models.CreatedAPIKey} "创建成功"
+// @Failure 400 {object} models.Response "参数错误或权限范围无效"
+// @Failure 403 {object} models.Response "不能使用 API Key 访问"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "查询数据库失败",
+		})
+		return
+	}
+
+	created, err := createAPIKey(&user, &req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "API Key 创建成功，请妥善保存，之后无法再次查看",
+		Data: created,
+	})
+}
+
+// @Summary 吊销我的 API Key
+// @Description 吊销当前用户的 API Key，立即失效
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "API Key ID"
+// @Success 200 {object} models.Response{data=models.APIKey} "吊销成功"
+// @Failure 403 {object} models.Response "不能使用 API Key 访问"
+// @Failure 404 {object} models.Response "API Key 不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/api-keys/{id} [delete]
+func RevokeMyAPIKey(c *gin.Context) {
+	key, err := revokeAPIKey(c.Param("id"), c.GetUint("user_id"))
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "API Key 已吊销",
+		Data: key,
+	})
+}
+
+// @Summary 查询全部 API Key
+// @Description 按用户查询 API Key（需 api-keys:manage 权限）
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Param user_id query uint false "用户ID"
+// @Success 200 {object} models.Response{data=[]models.APIKey} "查询成功"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/api-keys [get]
+func GetAPIKeys(c *gin.Context) {
+	query := config.DB.Model(&models.APIKey{}).Preload("User")
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: keys,
+	})
+}
+
+// @Summary 为用户创建 API Key
+// @Description 为指定用户或服务账号创建 API Key，管理权限范围只能授予具有管理权限的角色（需 api-keys:manage 权限）
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "用户ID"
+// @Param request body models.CreateAPIKeyRequest true "名称、权限范围和过期时间"
+// @Success 200 {object} models.Response{data=models.CreatedAPIKey} "创建成功"
+// @Failure 400 {object} models.Response "参数错误或权限范围无效"
+// @Failure 404 {object} models.Response "用户不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/users/{id}/api-keys [post]
+func CreateUserAPIKey(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的用户ID",
+		})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrUserNotFound
+		}
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	created, err := createAPIKey(&user, &req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "API Key 创建成功，请妥善保存，之后无法再次查看",
+		Data: created,
+	})
+}
+
+// @Summary 吊销 API Key
+// @Description 吊销任意用户的 API Key，立即失效（需 api-keys:manage 权限）
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "API Key ID"
+// @Success 200 {object} models.Response{data=models.APIKey} "吊销成功"
+// @Failure 404 {object} models.Response "API Key 不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	key, err := revokeAPIKey(c.Param("id"), 0)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "API Key 已吊销",
+		Data: key,
+	})
+}
+
+// @Summary 创建服务账号
+// @Description 创建不对应具体读者的服务账号（如自助借还机、报表脚本），服务账号不能登录，只能通过为其创建的 API Key 访问（需 users:manage 权限，指定读者以外的角色还需 roles:manage 权限）
+// @Tags api-keys
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateServiceAccountRequest true "用户名和角色"
+// @Success 200 {object} models.Response{data=models.User} "创建成功"
+// @Failure 400 {object} models.Response "参数错误或角色不存在"
+// @Failure 403 {object} models.Response "无权指定该角色"
+// @Failure 409 {object} models.Response "用户已存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/service-accounts [post]
+func CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.RoleUser
+	}
+	// 指定读者以外的角色等同于分配角色，与修改用户角色一样需要 roles:manage 权限
+	if req.Role != auth.RoleUser {
+		canAssign, err := auth.RoleHasPermission(config.DB, c.GetString("role"), auth.PermRolesManage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "查询数据库失败",
+			})
+			return
+		}
+		if !canAssign {
+			c.JSON(http.StatusForbidden, models.Response{
+				Code: 403,
+				Msg:  "权限不足，为服务账号指定读者以外的角色需要 " + auth.PermRolesManage + " 权限",
+			})
+			return
+		}
+	}
+	if exists, err := auth.RoleExists(config.DB, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "查询数据库失败",
+		})
+		return
+	} else if !exists {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  auth.ErrRoleNotFound.Error(),
+		})
+		return
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.User{}).Where("username = ?", req.Username).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "查询数据库失败",
+		})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.Response{
+			Code: 409,
+			Msg:  "用户已存在",
+		})
+		return
+	}
+
+	// 服务账号不使用密码，保存一个随机值的哈希，任何密码都无法通过校验
+	hashedPassword, err := utils.HashPassword(uuid.New().String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "密码加密错误",
+		})
+		return
+	}
+
+	account := models.User{
+		Username: req.Username,
+		Password: hashedPassword,
+		Role:     req.Role,
+		Service:  true,
+	}
+	if err := config.DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "创建用户失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "服务账号创建成功",
+		Data: account,
+	})
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestCreateServiceAccountRole(t *testing.T) {
+	db := setupTestDB(t)
+	if err := auth.SeedRoles(db); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+	auth.InvalidatePermissions()
+	t.Cleanup(auth.InvalidatePermissions)
+
+	// 只有 users:manage 没有 roles:manage 的自定义角色
+	userAdmin := models.Role{Name: "user_admin"}
+	if err := auth.SaveRole(db, &userAdmin, []string{auth.PermUsersManage}); err != nil {
+		t.Fatalf("创建角色失败: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		role     string
+		body     string
+		wantCode int
+	}{
+		{name: "默认读者角色", role: "user_admin", body: `{"username":"kiosk1"}`, wantCode: http.StatusOK},
+		{name: "显式指定读者角色", role: "user_admin", body: `{"username":"kiosk2","role":"user"}`, wantCode: http.StatusOK},
+		{name: "无 roles:manage 指定管理员", role: "user_admin", body: `{"username":"kiosk3","role":"admin"}`, wantCode: http.StatusForbidden},
+		{name: "无 roles:manage 指定馆员", role: "user_admin", body: `{"username":"kiosk4","role":"librarian"}`, wantCode: http.StatusForbidden},
+		{name: "管理员指定馆员", role: auth.RoleAdmin, body: `{"username":"kiosk5","role":"librarian"}`, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSON(http.MethodPost, "/service-accounts", "/service-accounts", tt.body, CreateServiceAccount,
+				map[string]interface{}{"role": tt.role})
+			if w.Code != tt.wantCode {
+				t.Fatalf("创建服务账号返回 %d，期望 %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&models.User{}).Where("service = ? AND role = ?", true, auth.RoleAdmin).Count(&count)
+	if count != 0 {
+		t.Errorf("创建了 %d 个管理员服务账号，期望 0", count)
+	}
+}
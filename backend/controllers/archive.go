@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrBookNotArchived = errors.New("图书未归档")
+
+// findArchivedBook 锁定并返回已归档的图书
+func findArchivedBook(tx *gorm.DB, id uint64) (*models.Book, error) {
+	var book models.Book
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookNotFound
+		}
+		return nil, err
+	}
+	if book.PurgedAt != nil {
+		return nil, ErrBookNotFound
+	}
+	if !book.DeletedAt.Valid {
+		return nil, ErrBookNotArchived
+	}
+	return &book, nil
+}
+
+// @Summary 恢复图书
+// @Description 恢复已归档的图书，重新出现在图书列表和检索中（需 books:delete 权限）
+// @Tags books
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Success 200 {object} models.Response{data=models.Book} "恢复成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 409 {object} models.Response "图书未归档"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/{id}/restore [post]
+func RestoreBook(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	var book *models.Book
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		book, err = findArchivedBook(tx, bookID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(book).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		book.DeletedAt = gorm.DeletedAt{}
+		return jobs.PromoteHolds(tx, book, time.Now(), config.HoldPickupWindow())
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
+		if errors.Is(err, ErrBookNotArchived) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "图书未归档",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "恢复图书失败",
+		})
+		return
+	}
+
+	search.SyncBooks(config.DB, book.ID)
+	config.DB.First(book, book.ID)
+	fillCoverURL(book)
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "图书已恢复",
+		Data: book,
+	})
+}
+
+// @Summary 彻底删除图书
+// @Description 永久删除已归档的图书及其单册、预约、分类标签关联和封面文件，不可恢复；借阅记录和费用作为历史保留，有借阅记录的图书保留书名和作者，标记 purged_at（需 books:delete 权限）
+// @Tags books
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Success 200 {object} models.Response "删除成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 409 {object} models.Response "图书未归档，须先归档"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/{id}/purge [delete]
+func PurgeBook(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	var book *models.Book
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		book, err = findArchivedBook(tx, bookID)
+		if err != nil {
+			return err
+		}
+
+		// 借阅记录引用单册，先解除引用再删除单册
+		if err := tx.Unscoped().Model(&models.BorrowRecord{}).
+			Where("book_id = ? AND copy_id IS NOT NULL", book.ID).
+			Update("copy_id", nil).Error; err != nil {
+			return ErrDeleteBook
+		}
+		if err := tx.Where("book_id = ?", book.ID).Delete(&models.Copy{}).Error; err != nil {
+			return ErrDeleteBook
+		}
+		if err := tx.Where("book_id = ?", book.ID).Delete(&models.Reservation{}).Error; err != nil {
+			return ErrDeleteBook
+		}
+
+		var loans int64
+		if err := tx.Unscoped().Model(&models.BorrowRecord{}).Where("book_id = ?", book.ID).Count(&loans).Error; err != nil {
+			return ErrDeleteBook
+		}
+		if loans == 0 {
+			// 同时删除分类、标签的关联记录
+			if err := tx.Unscoped().Select("Categories", "Tags").Delete(book).Error; err != nil {
+				return ErrDeleteBook
+			}
+			return nil
+		}
+
+		// 有借阅记录时保留书名和作者供历史显示，其余信息清除；ISBN 置空，不妨碍同一 ISBN 重新入库
+		if err := tx.Model(book).Association("Categories").Clear(); err != nil {
+			return ErrDeleteBook
+		}
+		if err := tx.Model(book).Association("Tags").Clear(); err != nil {
+			return ErrDeleteBook
+		}
+		if err := tx.Unscoped().Model(&models.Book{}).Where("id = ?", book.ID).Updates(map[string]interface{}{
+			"purged_at":     time.Now(),
+			"summary":       "",
+			"cover_path":    models.DefaultCoverPath,
+			"isbn13":        nil,
+			"isbn10":        "",
+			"initial_stock": 0,
+			"stock":         0,
+			"total_stock":   0,
+		}).Error; err != nil {
+			return ErrDeleteBook
+		}
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
+		if errors.Is(err, ErrBookNotArchived) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "图书未归档，须先归档",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "删除图书失败",
+		})
+		return
+	}
+
+	// 数据库记录已删除，封面删除失败只记录日志
+	discardCover(book.CoverPath)
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "图书已彻底删除",
+	})
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+// createArchivedBook 创建一本带单册、分类的已归档图书
+func createArchivedBook(t *testing.T, db *gorm.DB, isbn13 string) (*models.Book, *models.Copy) {
+	t.Helper()
+
+	book := models.Book{
+		Title:      "红楼梦",
+		Author:     "曹雪芹",
+		Summary:    "简介",
+		CoverPath:  models.DefaultCoverPath,
+		TotalStock: 1,
+		Stock:      1,
+		Categories: []models.Category{{Code: "I242", Name: "古典小说", Path: "/"}},
+	}
+	catalog.SetISBN(&book, isbn13)
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("创建图书失败: %v", err)
+	}
+	item := models.Copy{BookID: book.ID, Barcode: "B" + isbn13, Status: models.CopyStatusAvailable}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("创建单册失败: %v", err)
+	}
+	if err := db.Delete(&book).Error; err != nil {
+		t.Fatalf("归档图书失败: %v", err)
+	}
+	return &book, &item
+}
+
+func TestPurgeBookKeepsLoanHistory(t *testing.T) {
+	db := setupTestDB(t)
+	book, item := createArchivedBook(t, db, "9787020002207")
+
+	user := models.User{Username: "reader", Password: "x", Role: "user"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	returned := time.Now().Add(-24 * time.Hour)
+	record := models.BorrowRecord{
+		UserID:     user.ID,
+		BookID:     book.ID,
+		CopyID:     &item.ID,
+		BorrowDate: returned.Add(-7 * 24 * time.Hour),
+		ReturnDate: &returned,
+		Status:     models.BorrowStatusReturned,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	fee := models.Fee{UserID: user.ID, BorrowRecordID: record.ID, Type: models.FeeTypeOverdue, Amount: 100, Status: models.FeeStatusPaid}
+	if err := db.Create(&fee).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	target := fmt.Sprintf("/books/%d/purge", book.ID)
+	w := serve(http.MethodDelete, "/books/:id/purge", target, PurgeBook, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("彻底删除返回 %d: %s", w.Code, w.Body.String())
+	}
+
+	var copies int64
+	db.Model(&models.Copy{}).Where("book_id = ?", book.ID).Count(&copies)
+	if copies != 0 {
+		t.Errorf("单册未删除，剩余 %d 册", copies)
+	}
+
+	var kept models.BorrowRecord
+	if err := db.First(&kept, record.ID).Error; err != nil {
+		t.Fatalf("借阅记录丢失: %v", err)
+	}
+	if kept.CopyID != nil || kept.BookID != book.ID {
+		t.Errorf("借阅记录 copy_id=%v book_id=%d，期望 copy_id 为空、book_id 不变", kept.CopyID, kept.BookID)
+	}
+	if err := db.First(&models.Fee{}, fee.ID).Error; err != nil {
+		t.Errorf("费用记录丢失: %v", err)
+	}
+
+	var tombstone models.Book
+	if err := db.Unscoped().Preload("Categories").First(&tombstone, book.ID).Error; err != nil {
+		t.Fatalf("图书记录未保留: %v", err)
+	}
+	if tombstone.PurgedAt == nil || tombstone.Title != book.Title || tombstone.Author != book.Author {
+		t.Errorf("保留的图书 purged_at=%v title=%q author=%q", tombstone.PurgedAt, tombstone.Title, tombstone.Author)
+	}
+	if tombstone.ISBN13 != nil || tombstone.Summary != "" || len(tombstone.Categories) != 0 {
+		t.Errorf("保留的图书未清除 ISBN、简介或分类: %+v", tombstone)
+	}
+
+	// 已彻底删除的图书不能恢复或再次删除，也不妨碍同一 ISBN 重新入库
+	if w := serve(http.MethodPost, "/books/:id/restore", fmt.Sprintf("/books/%d/restore", book.ID), RestoreBook, nil); w.Code != http.StatusNotFound {
+		t.Errorf("恢复已彻底删除的图书返回 %d，期望 404", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/books/:id/purge", target, PurgeBook, nil); w.Code != http.StatusNotFound {
+		t.Errorf("重复彻底删除返回 %d，期望 404", w.Code)
+	}
+	dup, err := catalog.FindDuplicate(db, book.Title, book.Author, "9787020002207")
+	if err != nil || dup != nil {
+		t.Errorf("FindDuplicate 返回 %v, %v，期望无重复", dup, err)
+	}
+	again := models.Book{Title: book.Title, Author: book.Author}
+	catalog.SetISBN(&again, "9787020002207")
+	if err := db.Create(&again).Error; err != nil {
+		t.Errorf("同一 ISBN 重新入库失败: %v", err)
+	}
+}
+
+func TestPurgeBookWithoutLoansDeletesRow(t *testing.T) {
+	db := setupTestDB(t)
+	book, _ := createArchivedBook(t, db, "9787532726103")
+
+	w := serve(http.MethodDelete, "/books/:id/purge", fmt.Sprintf("/books/%d/purge", book.ID), PurgeBook, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("彻底删除返回 %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Unscoped().Model(&models.Book{}).Where("id = ?", book.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("没有借阅记录的图书应直接删除")
+	}
+	db.Table("book_categories").Where("book_id = ?", book.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("分类关联未删除")
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestGetBookRecentLoans(t *testing.T) {
+	db := setupTestDB(t)
+	if err := auth.SeedRoles(db); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+	auth.InvalidatePermissions()
+	t.Cleanup(auth.InvalidatePermissions)
+
+	book := models.Book{Title: "围城", Author: "钱锺书", CoverPath: models.DefaultCoverPath, TotalStock: 1, Stock: 1}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "reader", Password: "x", Role: "user"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	returned := time.Now().Add(-24 * time.Hour)
+	record := models.BorrowRecord{
+		UserID:     user.ID,
+		BookID:     book.ID,
+		BorrowDate: returned.Add(-7 * 24 * time.Hour),
+		ReturnDate: &returned,
+		Status:     models.BorrowStatusReturned,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   int
+	}{
+		{name: "管理员会话", values: map[string]interface{}{"role": auth.RoleAdmin}, want: 1},
+		{name: "普通读者", values: map[string]interface{}{"role": "user"}, want: 0},
+		{
+			name: "只读图书的 API Key",
+			values: map[string]interface{}{
+				"role":    auth.RoleAdmin,
+				"api_key": &models.APIKey{Scopes: []string{auth.ScopeReadBooks}},
+			},
+			want: 0,
+		},
+		{
+			name: "具有借阅管理范围的 API Key",
+			values: map[string]interface{}{
+				"role":    auth.RoleAdmin,
+				"api_key": &models.APIKey{Scopes: []string{auth.ScopeReadBooks, auth.ScopeAdminCirculation}},
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(http.MethodGet, "/books/:id", fmt.Sprintf("/books/%d", book.ID), GetBook, tt.values)
+			if w.Code != http.StatusOK {
+				t.Fatalf("查询图书返回 %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Data models.BookDetail `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("解析响应失败: %v", err)
+			}
+			if got := len(resp.Data.RecentLoans); got != tt.want {
+				t.Errorf("返回 %d 条最近借阅，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateBookISBN(t *testing.T) {
+	db := setupTestDB(t)
+
+	book := models.Book{Title: "围城", Author: "钱锺书", CoverPath: models.DefaultCoverPath}
+	catalog.SetISBN(&book, "9787020002207")
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		isbn     string
+		wantCode int
+		want13   string
+		want10   string
+	}{
+		{name: "不传时保留", isbn: "", wantCode: http.StatusOK, want13: "9787020002207", want10: "702000220X"},
+		{name: "校验位错误", isbn: "9787020002208", wantCode: http.StatusBadRequest, want13: "9787020002207", want10: "702000220X"},
+		{name: "修改为 ISBN-10", isbn: "0-306-40615-2", wantCode: http.StatusOK, want13: "9780306406157", want10: "0306406152"},
+		{name: "清除", isbn: "-", wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"summary": {"简介"}}
+			if tt.isbn != "" {
+				form.Set("isbn", tt.isbn)
+			}
+			w := serveForm(http.MethodPut, "/books/:id", fmt.Sprintf("/books/%d", book.ID), form, UpdateBook, nil)
+			if w.Code != tt.wantCode {
+				t.Fatalf("更新图书返回 %d，期望 %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			var got models.Book
+			db.First(&got, book.ID)
+			got13 := ""
+			if got.ISBN13 != nil {
+				got13 = *got.ISBN13
+			}
+			if got13 != tt.want13 || got.ISBN10 != tt.want10 {
+				t.Errorf("ISBN 为 %q/%q，期望 %q/%q", got13, got.ISBN10, tt.want13, tt.want10)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestBorrowBookLoanLimits(t *testing.T) {
+	t.Setenv("MAX_ACTIVE_LOANS_USER", "2")
+	t.Setenv("MAX_COPIES_PER_TITLE_USER", "1")
+	t.Setenv("BLOCK_ON_OVERDUE_USER", "true")
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().AddDate(0, 0, 7)
+
+	// loan 描述借书前已有的一条借阅，same 表示与本次借的是同一种书
+	type loan struct {
+		same   bool
+		status string
+		due    time.Time
+	}
+	tests := []struct {
+		name     string
+		loans    []loan
+		wantCode int
+		wantMsg  string
+	}{
+		{name: "无在借", wantCode: http.StatusOK},
+		{name: "未达在借上限", loans: []loan{{status: models.BorrowStatusBorrowed, due: future}}, wantCode: http.StatusOK},
+		{
+			name:     "达到在借上限",
+			loans:    []loan{{status: models.BorrowStatusBorrowed, due: future}, {status: models.BorrowStatusBorrowed, due: future}},
+			wantCode: http.StatusForbidden, wantMsg: ErrLoanLimit.Error(),
+		},
+		{
+			name:     "已归还的不计入",
+			loans:    []loan{{status: models.BorrowStatusReturned, due: future}, {same: true, status: models.BorrowStatusReturned, due: future}},
+			wantCode: http.StatusOK,
+		},
+		{name: "同一种书已在借", loans: []loan{{same: true, status: models.BorrowStatusBorrowed, due: future}}, wantCode: http.StatusForbidden, wantMsg: ErrTitleLimit.Error()},
+		{name: "已标记逾期", loans: []loan{{status: models.BorrowStatusOverdue, due: past}}, wantCode: http.StatusForbidden, wantMsg: ErrHasOverdue.Error()},
+		{name: "已过应还时间但尚未标记", loans: []loan{{status: models.BorrowStatusBorrowed, due: past}}, wantCode: http.StatusForbidden, wantMsg: ErrHasOverdue.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			user := createUser(t, db, "reader", "user")
+			book, _ := createBookWithCopies(t, db, "围城", 2)
+			other, _ := createBookWithCopies(t, db, "边城", 1)
+
+			for _, l := range tt.loans {
+				due := l.due
+				record := models.BorrowRecord{UserID: user.ID, BookID: other.ID, BorrowDate: due.AddDate(0, 0, -30), DueDate: &due, Status: l.status}
+				if l.same {
+					record.BookID = book.ID
+				}
+				if err := db.Create(&record).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := serveJSON(http.MethodPost, "/borrows", "/borrows", fmt.Sprintf(`{"id":%d}`, book.ID), BorrowBook,
+				map[string]interface{}{"user_id": user.ID})
+			if w.Code != tt.wantCode {
+				t.Fatalf("借书返回 %d，期望 %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantMsg != "" {
+				if msg := responseMsg(t, w.Body.Bytes()); msg != tt.wantMsg {
+					t.Errorf("错误信息为 %q，期望 %q", msg, tt.wantMsg)
+				}
+			}
+
+			var active int64
+			db.Model(&models.BorrowRecord{}).Where("user_id = ? AND book_id = ? AND status = ?", user.ID, book.ID, models.BorrowStatusBorrowed).Count(&active)
+			want := int64(0)
+			for _, l := range tt.loans {
+				if l.same && l.status == models.BorrowStatusBorrowed {
+					want++
+				}
+			}
+			if tt.wantCode == http.StatusOK {
+				want++
+			}
+			if active != want {
+				t.Errorf("在借该书 %d 册，期望 %d 册", active, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// respondClassificationError 将分类、标签相关错误映射为响应，其他错误返回 500 和 msg
+func respondClassificationError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, ErrBookNotFound), errors.Is(err, catalog.ErrCategoryNotFound), errors.Is(err, catalog.ErrTagNotFound):
+		c.JSON(http.StatusNotFound, models.Response{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, catalog.ErrParentNotFound), errors.Is(err, catalog.ErrCategoryCycle), errors.Is(err, catalog.ErrEmptyTag):
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, catalog.ErrCategoryCodeExists), errors.Is(err, catalog.ErrTagExists), errors.Is(err, catalog.ErrCategoryHasChildren):
+		c.JSON(http.StatusConflict, models.Response{
+			Code: 409,
+			Msg:  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  msg,
+		})
+	}
+}
+
+// @Summary 获取分类树
+// @Description 返回全部分类，按上下级组织为树，同级按分类号排序
+// @Tags categories
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.Category} "查询成功"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/categories [get]
+func GetCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := config.DB.Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: catalog.CategoryTree(categories),
+	})
+}
+
+// @Summary 新增分类
+// @Description 新增分类，parent_id 为空时为顶级分类（需 categories:manage 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CategoryRequest true "分类信息"
+// @Success 200 {object} models.Response{data=models.Category} "新增成功"
+// @Failure 400 {object} models.Response "参数错误或上级分类不存在"
+// @Failure 409 {object} models.Response "分类号已存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/categories [post]
+func CreateCategory(c *gin.Context) {
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	category := models.Category{Code: req.Code, Name: req.Name, ParentID: req.ParentID}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return catalog.SaveCategory(tx, &category)
+	})
+	if err != nil {
+		respondClassificationError(c, err, "新增分类失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "新增分类成功",
+		Data: category,
+	})
+}
+
+// @Summary 修改分类
+// @Description 修改分类号、名称或上级分类，移动分类时其下级分类随之移动（需 categories:manage 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "分类ID"
+// @Param request body models.CategoryRequest true "分类信息"
+// @Success 200 {object} models.Response{data=models.Category} "修改成功"
+// @Failure 400 {object} models.Response "参数错误、上级分类不存在或移动到自身下级"
+// @Failure 404 {object} models.Response "分类不存在"
+// @Failure 409 {object} models.Response "分类号已存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/categories/{id} [put]
+func UpdateCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的分类ID",
+		})
+		return
+	}
+
+	var req models.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	category := models.Category{ID: uint(id), Code: req.Code, Name: req.Name, ParentID: req.ParentID}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return catalog.SaveCategory(tx, &category)
+	})
+	if err != nil {
+		respondClassificationError(c, err, "修改分类失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "修改分类成功",
+		Data: category,
+	})
+}
+
+// @Summary 删除分类
+// @Description 删除没有下级分类的分类，已归入该分类的图书解除关联（需 categories:manage 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "分类ID"
+// @Success 200 {object} models.Response "删除成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "分类不存在"
+// @Failure 409 {object} models.Response "分类下还有子分类"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/categories/{id} [delete]
+func DeleteCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的分类ID",
+		})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return catalog.DeleteCategory(tx, uint(id))
+	})
+	if err != nil {
+		respondClassificationError(c, err, "删除分类失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "删除分类成功",
+	})
+}
+
+// @Summary 获取标签列表
+// @Description 返回全部标签，按名称排序
+// @Tags categories
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.Tag} "查询成功"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/tags [get]
+func GetTags(c *gin.Context) {
+	tags := []models.Tag{}
+	if err := config.DB.Order("name").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: tags,
+	})
+}
+
+// @Summary 新增标签
+// @Description 新增标签（需 categories:manage 权限），为图书设置标签时也会自动创建
+// @Tags categories
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.TagRequest true "标签信息"
+// @Success 200 {object} models.Response{data=models.Tag} "新增成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 409 {object} models.Response "标签已存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/tags [post]
+func CreateTag(c *gin.Context) {
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	tag := models.Tag{Name: req.Name}
+	if err := catalog.SaveTag(config.DB, &tag); err != nil {
+		respondClassificationError(c, err, "新增标签失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "新增标签成功",
+		Data: tag,
+	})
+}
+
+// @Summary 重命名标签
+// @Description 修改标签名称，已打上该标签的图书随之更新（需 categories:manage 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "标签ID"
+// @Param request body models.TagRequest true "标签信息"
+// @Success 200 {object} models.Response{data=models.Tag} "修改成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "标签不存在"
+// @Failure 409 {object} models.Response "标签已存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/tags/{id} [put]
+func UpdateTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的标签ID",
+		})
+		return
+	}
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	tag := models.Tag{ID: uint(id), Name: req.Name}
+	if err := catalog.SaveTag(config.DB, &tag); err != nil {
+		respondClassificationError(c, err, "修改标签失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "修改标签成功",
+		Data: tag,
+	})
+}
+
+// @Summary 删除标签
+// @Description 删除标签并从所有图书上移除（需 categories:manage 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "标签ID"
+// @Success 200 {object} models.Response "删除成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "标签不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/tags/{id} [delete]
+func DeleteTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的标签ID",
+		})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return catalog.DeleteTag(tx, uint(id))
+	})
+	if err != nil {
+		respondClassificationError(c, err, "删除标签失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "删除标签成功",
+	})
+}
+
+// @Summary 设置图书分类
+// @Description 用给定分类替换图书当前的全部分类（需 books:update 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Param request body models.SetBookCategoriesRequest true "分类ID列表"
+// @Success 200 {object} models.Response{data=[]models.Category} "设置成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书或分类不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/{id}/categories [put]
+func SetBookCategories(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	var req models.SetBookCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var book models.Book
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+		return catalog.SetBookCategories(tx, &book, req.CategoryIDs)
+	})
+	if err != nil {
+		respondClassificationError(c, err, "设置图书分类失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "设置图书分类成功",
+		Data: book.Categories,
+	})
+}
+
+// @Summary 设置图书标签
+// @Description 用给定标签替换图书当前的全部标签，不存在的标签自动创建（需 books:update 权限）
+// @Tags categories
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Param request body models.SetBookTagsRequest true "标签名称列表"
+// @Success 200 {object} models.Response{data=[]models.Tag} "设置成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/{id}/tags [put]
+func SetBookTags(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	var req models.SetBookTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var book models.Book
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+		return catalog.SetBookTags(tx, &book, req.Tags)
+	})
+	if err != nil {
+		respondClassificationError(c, err, "设置图书标签失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "设置图书标签成功",
+		Data: book.Tags,
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -8,8 +9,15 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
 	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/images"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/middleware"
 	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
 	"github.com/Dailiduzhou/library_manage_sys/utils"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -18,14 +26,35 @@ import (
 )
 
 var (
-	ErrBookNotFound   = errors.New("图书不存在")
-	ErrNoStock        = errors.New("图书库存不足")
-	ErrRecordNotFound = errors.New("借书记录查询失败")
-	ErrBookBorrowed   = errors.New("图书仍在借")
-	ErrDeleteBook     = errors.New("图书删除失败")
-	ErrDeleteCover    = errors.New("封面删除失败")
+	ErrBookNotFound    = errors.New("图书不存在")
+	ErrNoStock         = errors.New("图书库存不足")
+	ErrRecordNotFound  = errors.New("借书记录查询失败")
+	ErrBookBorrowed    = errors.New("图书仍在借")
+	ErrDeleteBook      = errors.New("图书删除失败")
+	ErrDeleteCover     = errors.New("封面删除失败")
+	ErrRenewLimit      = errors.New("已达到最大续借次数")
+	ErrRenewOverdue    = errors.New("逾期图书不可续借")
+	ErrHoldsPending    = errors.New("该书有其他读者在预约排队")
+	ErrBookOnHold      = errors.New("图书已为预约读者保留")
+	ErrFeesOutstanding = errors.New("未结清费用超过限额")
+	ErrLoanLimit       = errors.New("在借数量已达上限")
+	ErrTitleLimit      = errors.New("同一种书在借数量已达上限")
+	ErrHasOverdue      = errors.New("有逾期未还的图书")
+	ErrCopyNotFound    = errors.New("单册条码不存在")
+	ErrCopyUnavailable = errors.New("该单册当前不可借")
 )
 
+// 仍占用库存的借阅状态（在借或逾期）
+var activeBorrowStatuses = []string{models.BorrowStatusBorrowed, models.BorrowStatusOverdue}
+
+// 仍有效的预约状态（排队中或保留中）
+var activeReservationStatuses = []string{models.ReservationStatusWaiting, models.ReservationStatusReady}
+
+// withArchived 预加载图书时包含已归档的图书，借阅历史中仍显示书目信息
+func withArchived(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
 // @Summary 用户注册
 // @Description 创建新用户账号
 // @Tags auth
@@ -105,7 +134,7 @@ func Register(c *gin.Context) {
 // @Param request body models.LoginRequest true "登录请求"
 // @Success 200 {object} models.Response "登录成功"
 // @Failure 400 {object} models.Response "参数错误"
-// @Failure 403 {object} models.Response "认证失败"
+// @Failure 403 {object} models.Response "认证失败或账号已被禁用"
 // @Failure 500 {object} models.Response "服务器错误"
 // @Router /api/auth/login [post]
 func Login(c *gin.Context) {
@@ -120,35 +149,15 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	err = config.DB.Where("username = ?", req.Username).First(&user).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusForbidden, models.Response{
-			Code: 403,
-			Msg:  "用户不存在",
-		})
-		return
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{
-			Code: 500,
-			Msg:  "查询数据库失败",
-		})
-		return
-	}
-
-	err = utils.ComparePassword(user.Password, req.Password)
-	if err != nil {
-		c.JSON(http.StatusForbidden, models.Response{
-			Code: 403,
-			Msg:  "密码错误",
-		})
+	user, ok := checkCredentials(c, &req)
+	if !ok {
 		return
 	}
 
 	session := sessions.Default(c)
 	session.Set("user_id", user.ID)
 	session.Set("role", user.Role)
+	session.Set("login_at", time.Now().Unix())
 	if err := session.Save(); err != nil {
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Code: 500,
@@ -156,6 +165,9 @@ func Login(c *gin.Context) {
 		})
 		return
 	}
+	if err := middleware.TrackSession(session, user.ID); err != nil {
+		log.Printf("记录用户 %d 的会话失败: %v", user.ID, err)
+	}
 
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
@@ -167,8 +179,51 @@ func Login(c *gin.Context) {
 	})
 }
 
+// checkCredentials 校验用户名和密码，失败时已写入响应
+func checkCredentials(c *gin.Context, req *models.LoginRequest) (*models.User, bool) {
+	var user models.User
+	err := config.DB.Where("username = ?", req.Username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusForbidden, models.Response{
+			Code: 403,
+			Msg:  "用户不存在",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "查询数据库失败",
+		})
+		return nil, false
+	}
+
+	if err := utils.ComparePassword(user.Password, req.Password); err != nil {
+		c.JSON(http.StatusForbidden, models.Response{
+			Code: 403,
+			Msg:  "密码错误",
+		})
+		return nil, false
+	}
+	if user.Service {
+		c.JSON(http.StatusForbidden, models.Response{
+			Code: 403,
+			Msg:  "服务账号不能登录，请使用 API Key",
+		})
+		return nil, false
+	}
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, models.Response{
+			Code: 403,
+			Msg:  auth.ErrAccountDisabled.Error(),
+		})
+		return nil, false
+	}
+	return &user, true
+}
+
 // @Summary 用户登出
-// @Description 清除会话，登出当前用户
+// @Description 清除会话，登出当前用户；使用令牌认证时吊销当前访问令牌
 // @Tags auth
 // @Security ApiKeyAuth
 // @Produce json
@@ -176,6 +231,16 @@ func Login(c *gin.Context) {
 // @Failure 500 {object} models.Response "服务器错误"
 // @Router /api/auth/logout [post]
 func Logout(c *gin.Context) {
+	if claims, ok := c.Get("token_claims"); ok {
+		if err := auth.Revoke(claims.(*auth.Claims)); err != nil {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "登出失败",
+			})
+			return
+		}
+	}
+
 	session := sessions.Default(c)
 	session.Clear()
 	if err := session.Save(); err != nil {
@@ -183,6 +248,7 @@ func Logout(c *gin.Context) {
 			Code: 500,
 			Msg:  "登出失败",
 		})
+		return
 	}
 
 	// 希望前端实现跳转登录界面的功能
@@ -193,7 +259,7 @@ func Logout(c *gin.Context) {
 }
 
 // @Summary 创建图书
-// @Description 添加新图书（管理员权限）
+// @Description 添加新图书（需 books:create 权限）
 // @Tags books
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
@@ -201,11 +267,12 @@ func Logout(c *gin.Context) {
 // @Param title formData string true "书名"
 // @Param author formData string true "作者"
 // @Param summary formData string false "简介"
-// @Param cover formData file false "封面图片"
-// @Param initial_stock formData integer true "初始库存" minimum(0)
+// @Param isbn formData string false "ISBN-10 或 ISBN-13，提供时按 ISBN 判重"
+// @Param cover formData file false "封面图片，支持 JPEG、PNG、WebP，自动生成缩略图、中图和大图"
+// @Param initial_stock formData integer true "初始库存，按数量自动生成单册条码" minimum(0)
 // @Success 200 {object} models.Response{data=models.Book} "创建成功"
 // @Failure 400 {object} models.Response "参数错误"
-// @Failure 409 {object} models.Response "图书已存在"
+// @Failure 409 {object} models.Response "图书已存在(ISBN 相同，或未提供 ISBN 时书名和作者相同)"
 // @Failure 500 {object} models.Response "服务器错误"
 // @Router /api/admin/books [post]
 func CreateBook(c *gin.Context) {
@@ -219,64 +286,72 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
-	var existingBook models.Book
-	err := config.DB.Where("title = ? AND author = ?", req.Title, req.Author).First(&existingBook).Error
-	if err == nil {
-		c.JSON(http.StatusConflict, models.Response{
-			Code: 409,
-			Msg:  "该图书已存在(书名和作者相同)",
-		})
-		return
+	isbn13 := ""
+	if req.ISBN != "" {
+		normalized, err := catalog.NormalizeISBN(req.ISBN)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				Code: 400,
+				Msg:  "ISBN 格式或校验位错误",
+			})
+			return
+		}
+		isbn13 = normalized
 	}
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
+
+	existingBook, err := catalog.FindDuplicate(config.DB, req.Title, req.Author, isbn13)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Code: 500,
 			Msg:  "数据库查询失败",
 		})
 		return
 	}
+	if existingBook != nil {
+		msg := "该图书已存在(书名和作者相同)"
+		if isbn13 != "" {
+			msg = "该图书已存在(ISBN 相同)"
+		}
+		if existingBook.DeletedAt.Valid {
+			msg += "，已归档，可恢复后使用"
+		}
+		c.JSON(http.StatusConflict, models.Response{
+			Code: 409,
+			Msg:  msg,
+		})
+		return
+	}
 
 	finalCoverPath := models.DefaultCoverPath
 	if req.Cover != nil && req.Cover.Size > 0 {
 		log.Printf("有封面文件上传，大小: %d", req.Cover.Size)
 		savePath, err := utils.SaveImages(c, req.Cover)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.Response{
-				Code: 500,
-				Msg:  "图片保存失败",
-			})
+			respondCoverError(c, err)
 			return
 		}
 		finalCoverPath = savePath
 	} else {
 		log.Printf("没有封面文件上传或文件为空，使用默认路径: %s", finalCoverPath)
 	}
-	finalSummary := models.DefaultSummary
-	if req.Summary != "" {
-		finalSummary = req.Summary
-	}
 
-	newBook := models.Book{
+	draft := catalog.BookDraft{
 		Title:        req.Title,
 		Author:       req.Author,
-		Summary:      finalSummary,
+		Summary:      req.Summary,
+		ISBN:         isbn13,
 		CoverPath:    finalCoverPath,
 		InitialStock: req.InitialStock,
-		Stock:        req.InitialStock,
-		TotalStock:   req.InitialStock,
-	}
-
-	if err := config.DB.Create(&newBook).Error; err != nil {
-		if req.Cover != nil && req.Cover.Size != 0 {
-			if err := utils.RemoveFile(finalCoverPath); err != nil {
-				c.JSON(http.StatusInternalServerError, models.Response{
-					Code: 500,
-					Msg:  "删除封面失败",
-				})
-				return
-			}
-		}
+	}
 
+	var newBook *models.Book
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		book, err := draft.Create(tx)
+		newBook = book
+		return err
+	})
+	if err != nil {
+		discardCover(finalCoverPath)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Code: 500,
 			Msg:  "创建图书失败",
@@ -284,6 +359,9 @@ func CreateBook(c *gin.Context) {
 		return
 	}
 
+	search.SyncBooks(config.DB, newBook.ID)
+	fillCoverURL(newBook)
+
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
 		Msg:  "图书创建成功",
@@ -291,53 +369,298 @@ func CreateBook(c *gin.Context) {
 	})
 }
 
+// applyBookFilters 按 GetBooks 的查询参数过滤图书，导出等接口共用
+func applyBookFilters(query *gorm.DB, c *gin.Context) *gorm.DB {
+	if title := c.Query("title"); title != "" {
+		query = pinyinFilter(query, "title", title)
+	}
+	if author := c.Query("author"); author != "" {
+		query = pinyinFilter(query, "author", author)
+	}
+	if summary := c.Query("summary"); summary != "" {
+		query = query.Where("summary LIKE ?", "%"+summary+"%")
+	}
+	switch c.Query("archived") {
+	case "only":
+		query = query.Unscoped().Where("deleted_at IS NOT NULL AND purged_at IS NULL")
+	case "include":
+		query = query.Unscoped().Where("purged_at IS NULL")
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("id IN (?)", catalog.BooksInCategory(config.DB, category))
+	}
+	// 多个标签同时满足
+	for _, tag := range c.QueryArray("tag") {
+		query = query.Where("id IN (?)", catalog.BooksWithTag(config.DB, tag))
+	}
+	return query
+}
+
+// pinyinFilter 模糊匹配书名或作者，输入像拼音时（如 "hlm"、"hongloumeng"）同时匹配全拼和首字母
+func pinyinFilter(query *gorm.DB, column, value string) *gorm.DB {
+	key := search.PinyinKey(value)
+	if key == "" {
+		return query.Where(column+" LIKE ?", "%"+value+"%")
+	}
+	return query.Where(
+		fmt.Sprintf("(%s LIKE ? OR %s_pinyin LIKE ? OR %s_initials LIKE ?)", column, column, column),
+		"%"+value+"%", "%"+key+"%", key+"%",
+	)
+}
+
 // @Summary 获取图书列表
-// @Description 按条件查询图书
+// @Description 按条件查询图书，同时返回符合条件的图书在分类和标签上的分面统计
 // @Tags books
 // @Security ApiKeyAuth
 // @Produce json
-// @Param title query string false "按书名模糊查询"
-// @Param author query string false "按作者模糊查询"
+// @Param title query string false "按书名模糊查询，支持全拼和首字母"
+// @Param author query string false "按作者模糊查询，支持全拼和首字母"
 // @Param summary query string false "按简介模糊查询"
-// @Success 200 {object} models.Response{data=[]models.Book} "查询成功"
+// @Param category query string false "按分类号筛选，包含其所有下级分类"
+// @Param tag query []string false "按标签筛选，可传多个，须同时满足" collectionFormat(multi)
+// @Param archived query string false "已归档图书: 默认不含，only 只看已归档，include 包含已归档"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: title/author/created_at/stock，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，书名和作者默认升序，其余默认降序"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.Book,facets=models.BookFacets}} "查询成功"
+// @Failure 400 {object} models.Response "分页或排序参数错误"
 // @Failure 500 {object} models.Response "数据库错误"
 // @Router /api/books [get]
 func GetBooks(c *gin.Context) {
-	var books []models.Book
+	query := applyBookFilters(config.DB.Model(&models.Book{}), c)
+
+	result, err := paginate(c, query, bookSorts, func(b *models.Book) uint { return b.ID })
+	if err != nil {
+		respondPageError(c, err)
+		return
+	}
+
+	books := result.Items.([]models.Book)
+	if err := catalog.LoadClassification(config.DB, books); err != nil {
+		respondPageError(c, err)
+		return
+	}
+	fillBookCovers(books)
+	facets, err := catalog.Facets(config.DB, applyBookFilters(config.DB.Model(&models.Book{}), c).Select("id"), c.Query("category"))
+	if err != nil {
+		respondPageError(c, err)
+		return
+	}
+	result.Facets = facets
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: result,
+	})
+}
+
+// @Summary 按 ISBN 查询图书
+// @Description 支持 ISBN-10 与 ISBN-13，可带连字符
+// @Tags books
+// @Security ApiKeyAuth
+// @Produce json
+// @Param isbn path string true "ISBN"
+// @Success 200 {object} models.Response{data=models.Book} "查询成功"
+// @Failure 400 {object} models.Response "ISBN 格式错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 500 {object} models.Response "数据库错误"
+// @Router /api/books/isbn/{isbn} [get]
+func GetBookByISBN(c *gin.Context) {
+	isbn13, err := catalog.NormalizeISBN(c.Param("isbn"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "ISBN 格式或校验位错误",
+		})
+		return
+	}
 
-	title := c.Query("title")
-	author := c.Query("author")
-	summary := c.Query("summary")
+	var book models.Book
+	if err := config.DB.Where("isbn13 = ?", isbn13).First(&book).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+	fillCoverURL(&book)
 
-	query := config.DB.Model(&models.Book{})
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: book,
+	})
+}
 
-	if title != "" {
-		query = query.Where("title LIKE ?", "%"+title+"%")
+// discardCover 删除不再使用的封面，默认封面不删除；失败只记录日志，残留文件由封面清理任务回收
+func discardCover(path string) {
+	if path == "" || path == models.DefaultCoverPath {
+		return
 	}
-	if author != "" {
-		query = query.Where("author LIKE ?", "%"+author+"%")
+	if err := utils.RemoveFile(path); err != nil {
+		log.Printf("%v: path=%s, err=%v", ErrDeleteCover, path, err)
 	}
-	if summary != "" {
-		query = query.Where("summary LIKE ?", "%"+summary+"%")
+}
+
+// fillCoverURL 按当前封面存储生成图书的封面地址，返回图书的接口在响应前调用
+func fillCoverURL(book *models.Book) {
+	if book == nil {
+		return
+	}
+	book.CoverURL = storage.Covers.URL(book.CoverPath)
+	book.CoverThumbURL, book.CoverMediumURL = book.CoverURL, book.CoverURL
+	if key, ok := images.VariantKey(book.CoverPath, images.VariantThumb); ok {
+		book.CoverThumbURL = storage.Covers.URL(key)
+	}
+	if key, ok := images.VariantKey(book.CoverPath, images.VariantMedium); ok {
+		book.CoverMediumURL = storage.Covers.URL(key)
+	}
+}
+
+func fillBookCovers(books []models.Book) {
+	for i := range books {
+		fillCoverURL(&books[i])
+	}
+}
+
+// fillRecordCovers 填充借阅记录中预加载的图书封面地址
+func fillRecordCovers(records []models.BorrowRecord) {
+	for i := range records {
+		fillCoverURL(records[i].Book)
+	}
+}
+
+// respondCoverError 图片格式、大小或尺寸不合格返回 400，其余为存储失败
+func respondCoverError(c *gin.Context, err error) {
+	if images.IsInvalid(err) {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.Response{
+		Code: 500,
+		Msg:  "图片保存失败",
+	})
+}
+
+// recentLoanLimit 图书详情中返回的最近借阅记录条数
+const recentLoanLimit = 20
+
+// @Summary 获取图书详情
+// @Description 返回图书信息及实时可借情况：在架、借出和保留册数，最早的应还时间，预约排队人数；具有 records:read 权限的用户还会看到最近的借阅记录及借阅人（使用 API Key 时还需 admin:circulation 范围）
+// @Tags books
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Success 200 {object} models.Response{data=models.BookDetail} "查询成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 500 {object} models.Response "数据库错误"
+// @Router /api/books/{id} [get]
+func GetBook(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
 	}
 
-	if err := query.Order("id desc").Find(&books).Error; err != nil {
+	var detail models.BookDetail
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// 已归档的图书仍可查看，便于从借阅历史跳转
+		if err := tx.Unscoped().Preload("Categories").Preload("Tags").First(&detail.Book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		if err := tx.Model(&models.Copy{}).
+			Where("book_id = ? AND status = ?", bookID, models.CopyStatusOnLoan).
+			Count(&detail.OnLoan).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Copy{}).
+			Where("book_id = ? AND status = ?", bookID, models.CopyStatusOnHold).
+			Count(&detail.OnHold).Error; err != nil {
+			return err
+		}
+
+		var next models.BorrowRecord
+		err := tx.Where("book_id = ? AND status IN ? AND due_date IS NOT NULL", bookID, activeBorrowStatuses).
+			Order("due_date ASC").
+			Take(&next).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		detail.NextDueDate = next.DueDate
+
+		if err := tx.Model(&models.Reservation{}).
+			Where("book_id = ? AND status = ?", bookID, models.ReservationStatusWaiting).
+			Count(&detail.HoldQueue).Error; err != nil {
+			return err
+		}
+
+		canReadRecords, err := auth.RoleHasPermission(config.DB, c.GetString("role"), auth.PermRecordsRead)
+		if err != nil {
+			return err
+		}
+		// API Key 还需具有借阅管理范围，只读图书的集成不能看到借阅人
+		if v, ok := c.Get("api_key"); ok && !auth.HasScope(v.(*models.APIKey), auth.ScopeAdminCirculation) {
+			canReadRecords = false
+		}
+		if canReadRecords {
+			return tx.Preload("User").Preload("Copy").
+				Where("book_id = ?", bookID).
+				Order("borrow_date DESC, id DESC").
+				Limit(recentLoanLimit).
+				Find(&detail.RecentLoans).Error
+		}
+		return nil
+	}, &sql.TxOptions{ReadOnly: true})
+
+	if err != nil {
+		if errors.Is(err, ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Code: 500,
 			Msg:  "数据库查询失败",
 		})
 		return
 	}
+	fillCoverURL(&detail.Book)
 
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
 		Msg:  "查询成功",
-		Data: books,
+		Data: detail,
 	})
 }
 
+// clearISBN 更新图书时 isbn 传该值表示清除 ISBN，空值表示不修改
+const clearISBN = "-"
+
 // @Summary 更新图书
-// @Description 修改图书信息（管理员权限）
+// @Description 修改图书信息（需 books:update 权限），库存通过单册管理调整
 // @Tags books
 // @Security ApiKeyAuth
 // @Accept multipart/form-data
@@ -346,12 +669,12 @@ func GetBooks(c *gin.Context) {
 // @Param title formData string false "新书名"
 // @Param author formData string false "新作者"
 // @Param summary formData string false "新简介"
-// @Param cover formData file false "新封面"
-// @Param stock formData integer false "当前库存" minimum(0)
-// @Param total_stock formData integer false "总库存" minimum(0)
+// @Param isbn formData string false "新 ISBN-10 或 ISBN-13，传 - 清除 ISBN"
+// @Param cover formData file false "新封面，支持 JPEG、PNG、WebP"
 // @Success 200 {object} models.Response{data=models.Book} "更新成功"
 // @Failure 400 {object} models.Response "参数错误"
 // @Failure 404 {object} models.Response "图书不存在"
+// @Failure 409 {object} models.Response "ISBN 与其他图书重复"
 // @Failure 500 {object} models.Response "服务器错误"
 // @Router /api/admin/books/{id} [put]
 func UpdateBook(c *gin.Context) {
@@ -406,36 +729,52 @@ func UpdateBook(c *gin.Context) {
 
 	if req.Title != "" {
 		updates["title"] = req.Title
+		updates["title_pinyin"], updates["title_initials"] = search.ToPinyin(req.Title)
 	}
 	if req.Author != "" {
 		updates["author"] = req.Author
+		updates["author_pinyin"], updates["author_initials"] = search.ToPinyin(req.Author)
 	}
 	if req.Summary != "" {
 		updates["summary"] = req.Summary
 	}
-
-	if req.Stock >= 0 || req.TotalStock > 0 {
-		newStock := book.Stock
-		newTotalStock := book.TotalStock
-
-		if req.Stock >= 0 {
-			newStock = req.Stock
-		}
-		if req.TotalStock > 0 {
-			newTotalStock = req.TotalStock
-		}
-
-		if newStock > newTotalStock {
+	if req.ISBN == clearISBN {
+		catalog.SetISBN(&book, "")
+		updates["isbn13"] = book.ISBN13
+		updates["isbn10"] = book.ISBN10
+	} else if req.ISBN != "" {
+		isbn13, err := catalog.NormalizeISBN(req.ISBN)
+		if err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusBadRequest, models.Response{
 				Code: 400,
-				Msg:  "当前库存不能大于总库存",
+				Msg:  "ISBN 格式或校验位错误",
+			})
+			return
+		}
+
+		var count int64
+		// 已归档的图书仍占用 ISBN
+		if err := tx.Unscoped().Model(&models.Book{}).Where("isbn13 = ? AND id <> ?", isbn13, book.ID).Count(&count).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "数据库查询失败",
+			})
+			return
+		}
+		if count > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "ISBN 与其他图书重复",
 			})
 			return
 		}
 
-		updates["stock"] = newStock
-		updates["total_stock"] = newTotalStock
+		catalog.SetISBN(&book, isbn13)
+		updates["isbn13"] = book.ISBN13
+		updates["isbn10"] = book.ISBN10
 	}
 
 	finalCoverPath := book.CoverPath
@@ -443,10 +782,8 @@ func UpdateBook(c *gin.Context) {
 		log.Printf("有封面文件上传，大小: %d", req.Cover.Size)
 		savePath, err := utils.SaveImages(c, req.Cover)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.Response{
-				Code: 500,
-				Msg:  "图片保存失败",
-			})
+			tx.Rollback()
+			respondCoverError(c, err)
 			return
 		}
 		finalCoverPath = savePath
@@ -459,6 +796,9 @@ func UpdateBook(c *gin.Context) {
 	if len(updates) > 0 {
 		if err := tx.Model(&book).Updates(updates).Error; err != nil {
 			tx.Rollback()
+			if finalCoverPath != book.CoverPath {
+				discardCover(finalCoverPath)
+			}
 			c.JSON(http.StatusInternalServerError, models.Response{
 				Code: 500,
 				Msg:  "更新失败: " + err.Error(),
@@ -468,13 +808,22 @@ func UpdateBook(c *gin.Context) {
 	}
 
 	if err := tx.Commit().Error; err != nil {
+		if finalCoverPath != book.CoverPath {
+			discardCover(finalCoverPath)
+		}
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Code: 500,
 			Msg:  "提交事务失败: ",
 		})
 		return
 	}
+	// 换了新封面，旧封面不再被引用
+	if finalCoverPath != book.CoverPath {
+		discardCover(book.CoverPath)
+	}
 	config.DB.First(&book, req.ID)
+	search.SyncBooks(config.DB, book.ID)
+	fillCoverURL(&book)
 
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
@@ -483,13 +832,13 @@ func UpdateBook(c *gin.Context) {
 	})
 }
 
-// @Summary 删除图书
-// @Description 删除指定图书（管理员权限）
+// @Summary 归档图书
+// @Description 下架归档图书（软删除）：不再出现在图书列表和检索中，借阅历史中仍可见，可恢复；有在借单册时不能归档，仍有效的预约将被取消（需 books:delete 权限）
 // @Tags books
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path uint true "图书ID"
-// @Success 200 {object} models.Response "删除成功"
+// @Success 200 {object} models.Response "归档成功"
 // @Failure 400 {object} models.Response "参数错误"
 // @Failure 404 {object} models.Response "图书不存在"
 // @Failure 409 {object} models.Response "图书仍在借阅中"
@@ -511,44 +860,46 @@ func DeleteBooks(c *gin.Context) {
 	err = config.DB.Transaction(func(tx *gorm.DB) error {
 		var existingBook models.Book
 
-		if err := tx.First(&existingBook, req.ID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existingBook, req.ID).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return ErrBookNotFound
 			}
 			return err
 		}
 
-		if existingBook.Stock != existingBook.TotalStock {
+		var onLoan int64
+		if err := tx.Model(&models.BorrowRecord{}).
+			Where("book_id = ? AND status IN ?", existingBook.ID, activeBorrowStatuses).
+			Count(&onLoan).Error; err != nil {
+			return err
+		}
+		if onLoan > 0 {
 			return ErrBookBorrowed
 		}
 
-		if err := tx.Delete(&existingBook).Error; err != nil {
-			return ErrDeleteBook
+		// 取消仍有效的预约，为其保留的单册回到在架
+		var reservations []models.Reservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("book_id = ? AND status IN ?", existingBook.ID, activeReservationStatuses).
+			Find(&reservations).Error; err != nil {
+			return err
 		}
-
-		fmt.Printf("[Debug] DB路径: '%s' vs 默认常量: '%s'\n", existingBook.CoverPath, models.DefaultCoverPath)
-
-		if existingBook.CoverPath == models.DefaultCoverPath {
-			log.Printf("使用了默认封面,不执行删除")
-		} else {
-			if err := utils.RemoveFile(existingBook.CoverPath); err != nil {
-				return ErrDeleteCover
+		for i := range reservations {
+			if err := tx.Model(&reservations[i]).Update("status", models.ReservationStatusCancelled).Error; err != nil {
+				return err
+			}
+			if err := jobs.ReleaseHold(tx, &reservations[i]); err != nil {
+				return err
 			}
 		}
+		if err := jobs.SyncBookStock(tx, &existingBook); err != nil {
+			return err
+		}
 
-		// if existingBook.CoverPath == models.DefaultCoverPath {
-		// 	log.Printf("使用了默认封面, 跳过物理删除")
-		// } else {
-		// 	// 这里修改了！即使出错也不 return ErrDeleteCover
-		// 	err := utils.RemoveFile(existingBook.CoverPath)
-		// 	if err != nil {
-		// 		// 只在控制台打印一条警告，不影响 HTTP 返回 200
-		// 		fmt.Printf("[Warning] 封面文件删除失败 (不影响图书删除): path=%s, err=%v\n", existingBook.CoverPath, err)
-		// 	} else {
-		// 		fmt.Printf("[Info] 封面文件已删除: %s\n", existingBook.CoverPath)
-		// 	}
-		// }
-
+		// 单册、封面和分类保留，恢复后即可继续流通
+		if err := tx.Delete(&existingBook).Error; err != nil {
+			return ErrDeleteBook
+		}
 		return nil
 	})
 
@@ -560,17 +911,17 @@ func DeleteBooks(c *gin.Context) {
 			})
 			return
 		}
-		if errors.Is(err, ErrDeleteBook) {
-			c.JSON(http.StatusInternalServerError, models.Response{
-				Code: 500,
-				Msg:  "删除图书失败",
+		if errors.Is(err, ErrBookBorrowed) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "图书仍在借阅中",
 			})
 			return
 		}
-		if errors.Is(err, ErrDeleteCover) {
+		if errors.Is(err, ErrDeleteBook) {
 			c.JSON(http.StatusInternalServerError, models.Response{
 				Code: 500,
-				Msg:  "封面删除失败",
+				Msg:  "归档图书失败",
 			})
 			return
 		}
@@ -582,27 +933,30 @@ func DeleteBooks(c *gin.Context) {
 		return
 	}
 
+	search.SyncBooks(config.DB, req.ID)
+
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
-		Msg:  "删除图书成功",
+		Msg:  "图书已归档",
 	})
 }
 
 // @Summary 借阅图书
-// @Description 创建借阅记录，用户借阅指定图书 (return_date 初始为 null)
+// @Description 创建借阅记录，按图书ID或扫描单册条码借书 (return_date 初始为 null，due_date 按用户角色的借期计算)
 // @Tags borrows
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
-// @Param request body models.FindBookRequest true "借阅请求"
+// @Param request body models.CirculationRequest true "借阅请求"
 // @Success 200 {object} models.Response{data=models.BorrowRecord} "借阅成功"
 // @Failure 400 {object} models.Response "参数错误"
-// @Failure 404 {object} models.Response "图书不存在"
-// @Failure 409 {object} models.Response "库存不足"
+// @Failure 404 {object} models.Response "图书或条码不存在"
+// @Failure 403 {object} models.Response "未结清费用超过限额、在借数量超限或有逾期图书"
+// @Failure 409 {object} models.Response "库存不足、单册不可借或已为预约读者保留"
 // @Failure 500 {object} models.Response "服务器错误"
 // @Router /api/borrows [post]
 func BorrowBook(c *gin.Context) {
-	var req models.FindBookRequest
+	var req models.CirculationRequest
 	userID := c.GetUint("user_id")
 	if userID == 0 {
 		log.Println("严重错误: 上下文中没有获取到 user_id")
@@ -623,36 +977,90 @@ func BorrowBook(c *gin.Context) {
 
 	var borrowRecord models.BorrowRecord
 	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 扫码借书时由条码确定图书
+		bookID := req.ID
+		if req.Barcode != "" {
+			var scanned models.Copy
+			if err := tx.Where("barcode = ?", req.Barcode).First(&scanned).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrCopyNotFound
+				}
+				return err
+			}
+			bookID = scanned.BookID
+		}
+
+		// 锁定用户行，使同一用户的并发借书请求串行执行，避免同时越过借阅上限
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		if err := checkLoanLimits(tx, &user, bookID); err != nil {
+			return err
+		}
+
+		balance, err := jobs.OutstandingBalance(tx, userID)
+		if err != nil {
+			return err
+		}
+		if balance > config.FineBlockThreshold() {
+			return ErrFeesOutstanding
+		}
+
 		var book models.Book
 
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, req.ID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, bookID).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return ErrBookNotFound
 			}
 			return err
 		}
 
-		if book.Stock <= 0 {
-			return ErrNoStock
+		item, err := pickCopy(tx, book.ID, userID, req.Barcode)
+		if err != nil {
+			return err
 		}
 
-		if err := tx.Model(&book).Update("stock", book.Stock-1).Error; err != nil {
+		if err := tx.Model(item).Update("status", models.CopyStatusOnLoan).Error; err != nil {
 			return err
 		}
 
+		now := time.Now()
+		dueDate := config.GetPolicy(user.Role).DueDate(now)
 		borrowRecord = models.BorrowRecord{
 			UserID:     userID,
-			BookID:     req.ID,
-			BorrowDate: time.Now(),
+			BookID:     book.ID,
+			CopyID:     &item.ID,
+			BorrowDate: now,
+			DueDate:    &dueDate,
 			ReturnDate: nil,
-			Status:     "borrowed",
+			Status:     models.BorrowStatusBorrowed,
 		}
 
-		if err := tx.Omit("User", "Book").Create(&borrowRecord).Error; err != nil {
+		if err := tx.Omit("User", "Book", "Copy").Create(&borrowRecord).Error; err != nil {
 			return err
 		}
 
-		return nil
+		// 借到书即视为预约完成，若借走的不是为其保留的那一册，则把保留的单册放回
+		var reservations []models.Reservation
+		if err := tx.Where("user_id = ? AND book_id = ? AND status IN ?", userID, book.ID, activeReservationStatuses).
+			Find(&reservations).Error; err != nil {
+			return err
+		}
+		for i := range reservations {
+			r := &reservations[i]
+			if r.CopyID != nil && *r.CopyID != item.ID {
+				if err := jobs.ReleaseHold(tx, r); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(r).Update("status", models.ReservationStatusFulfilled).Error; err != nil {
+				return err
+			}
+		}
+
+		return jobs.PromoteHolds(tx, &book, now, config.HoldPickupWindow())
 	})
 
 	if err != nil {
@@ -663,6 +1071,13 @@ func BorrowBook(c *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, ErrCopyNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "单册条码不存在",
+			})
+			return
+		}
 		if errors.Is(err, ErrNoStock) {
 			c.JSON(http.StatusConflict, models.Response{
 				Code: 409,
@@ -670,6 +1085,34 @@ func BorrowBook(c *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, ErrCopyUnavailable) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "该单册当前不可借",
+			})
+			return
+		}
+		if errors.Is(err, ErrBookOnHold) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "图书已为预约读者保留，请先预约排队",
+			})
+			return
+		}
+		if errors.Is(err, ErrFeesOutstanding) {
+			c.JSON(http.StatusForbidden, models.Response{
+				Code: 403,
+				Msg:  "未结清费用超过限额，请先缴费",
+			})
+			return
+		}
+		if errors.Is(err, ErrLoanLimit) || errors.Is(err, ErrTitleLimit) || errors.Is(err, ErrHasOverdue) {
+			c.JSON(http.StatusForbidden, models.Response{
+				Code: 403,
+				Msg:  err.Error(),
+			})
+			return
+		}
 
 		c.JSON(http.StatusInternalServerError, models.Response{
 			Code: 500,
@@ -685,20 +1128,127 @@ func BorrowBook(c *gin.Context) {
 	})
 }
 
+// pickCopy 选出本次借出的单册并加锁：扫码时使用该条码，
+// 否则优先取为当前用户保留的单册，再取任一在架单册
+func pickCopy(tx *gorm.DB, bookID, userID uint, barcode string) (*models.Copy, error) {
+	var hold models.Reservation
+	hasHold := true
+	if err := tx.Where("user_id = ? AND book_id = ? AND status = ? AND copy_id IS NOT NULL",
+		userID, bookID, models.ReservationStatusReady).First(&hold).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		hasHold = false
+	}
+
+	var item models.Copy
+	if barcode != "" {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("barcode = ?", barcode).First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrCopyNotFound
+			}
+			return nil, err
+		}
+
+		switch item.Status {
+		case models.CopyStatusAvailable:
+			return &item, nil
+		case models.CopyStatusOnHold:
+			if hasHold && *hold.CopyID == item.ID {
+				return &item, nil
+			}
+			return nil, ErrBookOnHold
+		default:
+			return nil, ErrCopyUnavailable
+		}
+	}
+
+	if hasHold {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, *hold.CopyID).Error; err != nil {
+			return nil, err
+		}
+		return &item, nil
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("book_id = ? AND status = ?", bookID, models.CopyStatusAvailable).
+		Order("id ASC").
+		First(&item).Error
+	if err == nil {
+		return &item, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 没有在架单册时区分是全部借出还是已为其他预约读者保留
+	var held int64
+	if err := tx.Model(&models.Copy{}).
+		Where("book_id = ? AND status = ?", bookID, models.CopyStatusOnHold).
+		Count(&held).Error; err != nil {
+		return nil, err
+	}
+	if held > 0 {
+		return nil, ErrBookOnHold
+	}
+	return nil, ErrNoStock
+}
+
+// checkLoanLimits 按用户角色的流通策略检查在借数量与逾期情况，调用方需已锁定用户行
+func checkLoanLimits(tx *gorm.DB, user *models.User, bookID uint) error {
+	policy := config.GetPolicy(user.Role)
+
+	var active int64
+	if err := tx.Model(&models.BorrowRecord{}).
+		Where("user_id = ? AND status IN ?", user.ID, activeBorrowStatuses).
+		Count(&active).Error; err != nil {
+		return err
+	}
+	if active >= int64(policy.MaxActiveLoans) {
+		return ErrLoanLimit
+	}
+
+	var sameTitle int64
+	if err := tx.Model(&models.BorrowRecord{}).
+		Where("user_id = ? AND book_id = ? AND status IN ?", user.ID, bookID, activeBorrowStatuses).
+		Count(&sameTitle).Error; err != nil {
+		return err
+	}
+	if sameTitle >= int64(policy.MaxCopiesPerTitle) {
+		return ErrTitleLimit
+	}
+
+	if policy.BlockOnOverdue {
+		// 扫描任务可能还未标记，直接按应还时间判断
+		var overdue int64
+		if err := tx.Model(&models.BorrowRecord{}).
+			Where("user_id = ? AND status IN ? AND (status = ? OR due_date < ?)",
+				user.ID, activeBorrowStatuses, models.BorrowStatusOverdue, time.Now()).
+			Count(&overdue).Error; err != nil {
+			return err
+		}
+		if overdue > 0 {
+			return ErrHasOverdue
+		}
+	}
+
+	return nil
+}
+
 // @Summary 归还图书
-// @Description 更新借阅状态，用户归还图书
+// @Description 更新借阅状态，按图书ID或扫描单册条码还书，管理员扫码可归还任意读者的借阅
 // @Tags borrows
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
-// @Param request body models.FindBookRequest true "归还请求"
+// @Param request body models.CirculationRequest true "归还请求"
 // @Success 200 {object} models.Response{data=models.BorrowRecord} "归还成功"
 // @Failure 400 {object} models.Response "参数错误"
-// @Failure 404 {object} models.Response "记录不存在"
+// @Failure 404 {object} models.Response "记录或条码不存在"
 // @Failure 500 {object} models.Response "服务器错误"
 // @Router /api/borrows/return [post]
 func ReturnBook(c *gin.Context) {
-	var req models.FindBookRequest
+	var req models.CirculationRequest
 
 	userID := c.GetUint("user_id")
 	if userID == 0 {
@@ -720,40 +1270,74 @@ func ReturnBook(c *gin.Context) {
 
 	var borrowRecord models.BorrowRecord
 	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("user_id = ? AND book_id = ? AND status = ?", userID, req.ID, "borrowed").
-			First(&borrowRecord).Error; err != nil {
+		var actor models.User
+		if err := tx.First(&actor, userID).Error; err != nil {
+			return err
+		}
+
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("status IN ?", activeBorrowStatuses)
+		if req.Barcode != "" {
+			var item models.Copy
+			if err := tx.Where("barcode = ?", req.Barcode).First(&item).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrCopyNotFound
+				}
+				return err
+			}
+			query = query.Where("copy_id = ?", item.ID)
+			// 有服务台权限的馆员扫码可归还任意读者借出的单册
+			atDesk, err := auth.RoleHasPermission(config.DB, actor.Role, auth.PermCirculationDesk)
+			if err != nil {
+				return err
+			}
+			if !atDesk {
+				query = query.Where("user_id = ?", userID)
+			}
+		} else {
+			query = query.Where("user_id = ? AND book_id = ?", userID, req.ID)
+		}
+
+		if err := query.First(&borrowRecord).Error; err != nil {
 			return ErrRecordNotFound
 		}
 
 		var book models.Book
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, req.ID).Error; err != nil {
-			if errors.Is(err, ErrBookNotFound) {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, borrowRecord.BookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return ErrBookNotFound
 			}
 			return err
 		}
 
-		if err := tx.Model(&book).Update("stock", book.Stock+1).Error; err != nil {
-			return err
-		}
-
-		if err := tx.Where("user_id = ? AND book_id = ? AND status = ?", userID, req.ID, "borrowed").First(&borrowRecord).Error; err != nil {
-			return ErrRecordNotFound
+		if borrowRecord.CopyID != nil {
+			if err := tx.Model(&models.Copy{}).
+				Where("id = ? AND status = ?", *borrowRecord.CopyID, models.CopyStatusOnLoan).
+				Update("status", models.CopyStatusAvailable).Error; err != nil {
+				return err
+			}
 		}
 
 		now := time.Now()
 		borrowRecord.ReturnDate = &now
-		borrowRecord.Status = "returned"
+		borrowRecord.Status = models.BorrowStatusReturned
+
+		var borrower models.User
+		if err := tx.First(&borrower, borrowRecord.UserID).Error; err != nil {
+			return err
+		}
+		if err := jobs.AccrueOverdueFee(tx, &borrowRecord, borrower.Role, now); err != nil {
+			return err
+		}
 
 		if err := tx.Model(&borrowRecord).Updates(models.BorrowRecord{
 			ReturnDate: &now,
-			Status:     "returned",
+			Status:     models.BorrowStatusReturned,
 		}).Error; err != nil {
 			return err
 		}
 
-		return nil
+		// 归还的单册优先保留给预约队首的读者
+		return jobs.PromoteHolds(tx, &book, now, config.HoldPickupWindow())
 	})
 
 	if err != nil {
@@ -765,6 +1349,14 @@ func ReturnBook(c *gin.Context) {
 			return
 		}
 
+		if errors.Is(err, ErrCopyNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "单册条码不存在",
+			})
+			return
+		}
+
 		if errors.Is(err, ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, models.Response{
 				Code: 404,
@@ -787,13 +1379,152 @@ func ReturnBook(c *gin.Context) {
 	})
 }
 
+// @Summary 续借图书
+// @Description 延长在借记录的应还时间，续借次数受角色策略限制，该书有人预约时拒绝续借
+// @Tags borrows
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "借阅记录ID"
+// @Success 200 {object} models.Response{data=models.BorrowRecord} "续借成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "记录不存在"
+// @Failure 409 {object} models.Response "超过续借次数、已逾期或有人预约"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/borrows/{id}/renew [post]
+func RenewBook(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		log.Println("严重错误: 上下文中没有获取到 user_id")
+		c.JSON(http.StatusUnauthorized, models.Response{
+			Code: 401,
+			Msg:  "用户未登录或认证失效",
+		})
+		return
+	}
+
+	recordID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的借阅记录ID",
+		})
+		return
+	}
+
+	var borrowRecord models.BorrowRecord
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND status IN ?", recordID, userID, activeBorrowStatuses).
+			First(&borrowRecord).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		if borrowRecord.Status == models.BorrowStatusOverdue ||
+			(borrowRecord.DueDate != nil && borrowRecord.DueDate.Before(now)) {
+			return ErrRenewOverdue
+		}
+
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		policy := config.GetPolicy(user.Role)
+		if borrowRecord.RenewCount >= policy.MaxRenewals {
+			return ErrRenewLimit
+		}
+
+		pending, err := hasPendingHolds(tx, borrowRecord.BookID, userID)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrHoldsPending
+		}
+
+		oldDueDate := now
+		if borrowRecord.DueDate != nil {
+			oldDueDate = *borrowRecord.DueDate
+		}
+		newDueDate := policy.DueDate(oldDueDate)
+
+		if err := tx.Model(&borrowRecord).Updates(map[string]interface{}{
+			"due_date":    newDueDate,
+			"renew_count": borrowRecord.RenewCount + 1,
+		}).Error; err != nil {
+			return err
+		}
+
+		renewal := models.Renewal{
+			BorrowRecordID: borrowRecord.ID,
+			UserID:         userID,
+			OldDueDate:     oldDueDate,
+			NewDueDate:     newDueDate,
+		}
+		if err := tx.Create(&renewal).Error; err != nil {
+			return err
+		}
+
+		borrowRecord.DueDate = &newDueDate
+		borrowRecord.RenewCount++
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "未找到该借阅记录或已归还",
+			})
+			return
+		}
+		if errors.Is(err, ErrRenewLimit) || errors.Is(err, ErrRenewOverdue) || errors.Is(err, ErrHoldsPending) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "系统繁忙,请稍后再试",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "续借成功",
+		Data: borrowRecord,
+	})
+}
+
+// hasPendingHolds 判断除 userID 外是否有其他读者在排队等待该书
+func hasPendingHolds(tx *gorm.DB, bookID, userID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.Reservation{}).
+		Where("book_id = ? AND user_id <> ? AND status = ?", bookID, userID, models.ReservationStatusWaiting).
+		Count(&count).Error
+	return count > 0, err
+}
+
 // @Summary 查询个人借书记录
 // @Description 查询当前登录用户的借阅记录（需登录）
 // @Tags borrows
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path uint true "用户ID"
-// @Success 200 {object} models.Response{data=[]models.BorrowRecord} "查询成功"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: created_at/borrow_date，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，默认 desc"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.BorrowRecord}} "查询成功"
 // @Failure 400 {object} models.Response "权限不足，无法查询他人记录"
 // @Failure 404 {object} models.Response "查询成功,无借书记录"
 // @Failure 500 {object} models.Response "用户ID解析错误或数据库查询失败"
@@ -802,9 +1533,7 @@ func BorrowRecords(c *gin.Context) {
 	id := c.Param("id")
 	userID, err := strconv.ParseUint(id, 10, 32)
 
-	session := sessions.Default(c)
-	userID_session := session.Get("user_id")
-	if userID_session == nil || uint(userID) != userID_session.(uint) {
+	if uint(userID) != c.GetUint("user_id") {
 		c.JSON(http.StatusBadRequest, models.Response{
 			Code: 400,
 			Msg:  "仅可查询自己的借书记录",
@@ -820,20 +1549,15 @@ func BorrowRecords(c *gin.Context) {
 		return
 	}
 
-	var records []models.BorrowRecord
-	query := config.DB.Model(&models.BorrowRecord{})
-
-	query = config.DB.Where("user_id = ?", userID)
+	query := config.DB.Model(&models.BorrowRecord{}).Where("user_id = ?", userID)
 
-	if err := query.Order("id DESC").Find(&records).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{
-			Code: 500,
-			Msg:  "数据库查询失败",
-		})
+	result, err := paginate(c, query, recordSorts, func(r *models.BorrowRecord) uint { return r.ID })
+	if err != nil {
+		respondPageError(c, err)
 		return
 	}
 
-	if len(records) == 0 {
+	if result.Total == 0 {
 		c.JSON(http.StatusNotFound, models.Response{
 			Code: 404,
 			Msg:  "查询成功,无借书记录",
@@ -844,31 +1568,34 @@ func BorrowRecords(c *gin.Context) {
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
 		Msg:  "查询成功",
-		Data: records,
+		Data: result,
 	})
 }
 
 // @Summary 查询所有借书记录
-// @Description 查询所有借阅记录（需管理员权限）
+// @Description 查询所有借阅记录（需 records:read 权限）
 // @Tags records
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {object} models.Response{data=[]models.BorrowRecord} "查询成功"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: created_at/borrow_date，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，默认 desc"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.BorrowRecord}} "查询成功"
+// @Failure 400 {object} models.Response "分页或排序参数错误"
 // @Failure 404 {object} models.Response "查询成功,无借书记录"
 // @Failure 500 {object} models.Response "数据库查询失败"
 // @Router /api/admin/records [get]
 func GetAllBorrowRecords(c *gin.Context) {
-	var records []models.BorrowRecord
 	query := config.DB.Model(&models.BorrowRecord{})
-	if err := query.Order("id DESC").Find(&records).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{
-			Code: 500,
-			Msg:  "数据库查询失败",
-		})
+	result, err := paginate(c, query, recordSorts, func(r *models.BorrowRecord) uint { return r.ID })
+	if err != nil {
+		respondPageError(c, err)
 		return
 	}
 
-	if len(records) == 0 {
+	if result.Total == 0 {
 		c.JSON(http.StatusNotFound, models.Response{
 			Code: 404,
 			Msg:  "查询成功,无借书记录",
@@ -879,17 +1606,23 @@ func GetAllBorrowRecords(c *gin.Context) {
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
 		Msg:  "查询成功",
-		Data: records,
+		Data: result,
 	})
 }
 
 // @Summary 按用户ID查询借阅记录
-// @Description 查询指定用户ID的借阅记录（需管理员权限）
+// @Description 查询指定用户ID的借阅记录（需 records:read 权限）
 // @Tags records
 // @Security ApiKeyAuth
 // @Produce json
 // @Param id path uint true "用户ID"
-// @Success 200 {object} models.Response{data=[]models.BorrowRecord} "查询成功"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: created_at/borrow_date，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，默认 desc"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.BorrowRecord}} "查询成功"
+// @Failure 400 {object} models.Response "分页或排序参数错误"
 // @Failure 404 {object} models.Response "查询成功,无借书记录"
 // @Failure 500 {object} models.Response "用户ID解析错误或数据库查询失败"
 // @Router /api/admin/records/{id} [post]
@@ -904,20 +1637,15 @@ func BorrowRecordsByID(c *gin.Context) {
 		return
 	}
 
-	var records []models.BorrowRecord
-	query := config.DB.Model(&models.BorrowRecord{})
-
-	query = config.DB.Where("user_id = ?", userID)
+	query := config.DB.Model(&models.BorrowRecord{}).Where("user_id = ?", userID)
 
-	if err := query.Order("id DESC").Find(&records).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{
-			Code: 500,
-			Msg:  "数据库查询失败",
-		})
+	result, err := paginate(c, query, recordSorts, func(r *models.BorrowRecord) uint { return r.ID })
+	if err != nil {
+		respondPageError(c, err)
 		return
 	}
 
-	if len(records) == 0 {
+	if result.Total == 0 {
 		c.JSON(http.StatusNotFound, models.Response{
 			Code: 404,
 			Msg:  "查询成功,无借书记录",
@@ -928,6 +1656,48 @@ func BorrowRecordsByID(c *gin.Context) {
 	c.JSON(http.StatusOK, models.Response{
 		Code: 200,
 		Msg:  "查询成功",
-		Data: records,
+		Data: result,
+	})
+}
+
+// @Summary 查询逾期借阅记录
+// @Description 查询所有逾期未还的借阅记录，附带用户和图书信息（需 records:read 权限）
+// @Tags records
+// @Security ApiKeyAuth
+// @Produce json
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: due_date/created_at/borrow_date，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，默认 desc"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.BorrowRecord}} "查询成功"
+// @Failure 400 {object} models.Response "分页或排序参数错误"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/records/overdue [get]
+func GetOverdueRecords(c *gin.Context) {
+	// 扫描任务有执行间隔，这里先同步一次，保证结果实时
+	if _, err := jobs.MarkOverdue(config.DB, time.Now()); err != nil {
+		log.Printf("同步逾期状态失败: %v", err)
+	}
+
+	query := config.DB.Model(&models.BorrowRecord{}).
+		Where("status = ? AND due_date IS NOT NULL", models.BorrowStatusOverdue)
+	result, err := paginate(c, query, overdueSorts, func(r *models.BorrowRecord) uint { return r.ID })
+	if err != nil {
+		respondPageError(c, err)
+		return
+	}
+
+	records := result.Items.([]models.BorrowRecord)
+	if err := preloadPage(config.DB.Preload("User").Preload("Book", withArchived), records, func(r *models.BorrowRecord) uint { return r.ID }); err != nil {
+		respondPageError(c, err)
+		return
+	}
+	fillRecordCovers(records)
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: result,
 	})
 }
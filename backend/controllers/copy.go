@@ -0,0 +1,348 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrBarcodeExists = errors.New("条码已存在")
+	ErrCopyInUse     = errors.New("单册已借出或保留中，不能修改状态")
+	ErrBookArchived  = errors.New("图书已归档")
+)
+
+// @Summary 查询图书单册
+// @Description 查询指定图书的全部单册（需 copies:manage 权限）
+// @Tags copies
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Success 200 {object} models.Response{data=[]models.Copy} "查询成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/books/{id}/copies [get]
+func GetBookCopies(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	// 已归档图书的单册仍可查看，便于注销或恢复前核对
+	var book models.Book
+	err = config.DB.Unscoped().Select("id", "purged_at").First(&book, bookID).Error
+	if err == nil && book.PurgedAt != nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	var copies []models.Copy
+	if err := config.DB.Where("book_id = ?", bookID).Order("id ASC").Find(&copies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: copies,
+	})
+}
+
+// @Summary 按条码查询单册
+// @Description 扫码查询单册及所属图书（需 copies:manage 权限）
+// @Tags copies
+// @Security ApiKeyAuth
+// @Produce json
+// @Param barcode path string true "单册条码"
+// @Success 200 {object} models.Response{data=models.Copy} "查询成功"
+// @Failure 404 {object} models.Response "条码不存在"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/copies/barcode/{barcode} [get]
+func GetCopyByBarcode(c *gin.Context) {
+	var item models.Copy
+	if err := config.DB.Preload("Book", withArchived).Where("barcode = ?", c.Param("barcode")).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "单册条码不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+	fillCoverURL(item.Book)
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: item,
+	})
+}
+
+// @Summary 新增单册
+// @Description 为图书新增单册，指定条码时新增一册，否则按 count 自动生成条码（需 copies:manage 权限）
+// @Tags copies
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Param request body models.CreateCopyRequest true "单册信息"
+// @Success 200 {object} models.Response{data=[]models.Copy} "新增成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 409 {object} models.Response "条码已存在或图书已归档"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/{id}/copies [post]
+func AddCopies(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	var req models.CreateCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var copies []models.Copy
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, bookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+		if book.PurgedAt != nil {
+			return ErrBookNotFound
+		}
+		if book.DeletedAt.Valid {
+			return ErrBookArchived
+		}
+
+		if req.Barcode != "" {
+			var exists int64
+			if err := tx.Model(&models.Copy{}).Where("barcode = ?", req.Barcode).Count(&exists).Error; err != nil {
+				return err
+			}
+			if exists > 0 {
+				return ErrBarcodeExists
+			}
+
+			item := models.Copy{
+				BookID:    book.ID,
+				Barcode:   req.Barcode,
+				Location:  req.Location,
+				Condition: models.CopyConditionGood,
+				Status:    models.CopyStatusAvailable,
+			}
+			if req.Condition != "" {
+				item.Condition = req.Condition
+			}
+			if err := tx.Omit("Book").Create(&item).Error; err != nil {
+				return err
+			}
+			copies = []models.Copy{item}
+		} else {
+			count := req.Count
+			if count == 0 {
+				count = 1
+			}
+			created, err := jobs.CreateCopies(tx, book.ID, count, req.Location)
+			if err != nil {
+				return err
+			}
+			copies = created
+		}
+
+		// 新入藏的单册优先分配给排队中的预约
+		return jobs.PromoteHolds(tx, &book, time.Now(), config.HoldPickupWindow())
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
+		if errors.Is(err, ErrBarcodeExists) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "条码已存在",
+			})
+			return
+		}
+		if errors.Is(err, ErrBookArchived) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "图书已归档，恢复后才能新增单册",
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "新增单册失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "新增单册成功",
+		Data: copies,
+	})
+}
+
+// @Summary 更新单册
+// @Description 修改单册位置、品相或状态，如标记损坏、遗失、注销或修复上架（需 copies:manage 权限）
+// @Tags copies
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "单册ID"
+// @Param request body models.UpdateCopyRequest true "单册信息"
+// @Success 200 {object} models.Response{data=models.Copy} "更新成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "单册不存在"
+// @Failure 409 {object} models.Response "单册已借出或保留中"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/copies/{id} [put]
+func UpdateCopy(c *gin.Context) {
+	copyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的单册ID",
+		})
+		return
+	}
+
+	var req models.UpdateCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var item models.Copy
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&item, copyID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCopyNotFound
+			}
+			return err
+		}
+
+		// 与借还书保持相同的加锁顺序：先图书后单册；已归档图书的单册仍可修改，如标记注销
+		var book models.Book
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, item.BookID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, copyID).Error; err != nil {
+			return err
+		}
+
+		updates := make(map[string]interface{})
+		if req.Location != "" {
+			updates["location"] = req.Location
+		}
+		if req.Condition != "" {
+			updates["condition"] = req.Condition
+		}
+		if req.Status != "" && req.Status != item.Status {
+			if item.Status == models.CopyStatusOnLoan || item.Status == models.CopyStatusOnHold {
+				return ErrCopyInUse
+			}
+			updates["status"] = req.Status
+		}
+
+		if len(updates) > 0 {
+			if err := tx.Model(&item).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		// 已归档的图书没有排队中的预约
+		if book.DeletedAt.Valid {
+			return nil
+		}
+		return jobs.PromoteHolds(tx, &book, time.Now(), config.HoldPickupWindow())
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrCopyNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "单册不存在",
+			})
+			return
+		}
+		if errors.Is(err, ErrCopyInUse) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  "单册已借出或保留中，不能修改状态",
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "更新单册失败",
+		})
+		return
+	}
+	config.DB.First(&item, copyID)
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "更新单册成功",
+		Data: item,
+	})
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestCopiesOfArchivedBook(t *testing.T) {
+	db := setupTestDB(t)
+	book, item := createArchivedBook(t, db, "9787020002207")
+
+	w := serve(http.MethodGet, "/books/:id/copies", fmt.Sprintf("/books/%d/copies", book.ID), GetBookCopies, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("查询已归档图书的单册返回 %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveJSON(http.MethodPost, "/books/:id/copies", fmt.Sprintf("/books/%d/copies", book.ID), `{"count":1}`, AddCopies, nil)
+	if w.Code != http.StatusConflict {
+		t.Errorf("为已归档图书新增单册返回 %d，期望 409: %s", w.Code, w.Body.String())
+	}
+
+	w = serveJSON(http.MethodPut, "/copies/:id", fmt.Sprintf("/copies/%d", item.ID), `{"status":"withdrawn"}`, UpdateCopy, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("修改已归档图书的单册返回 %d: %s", w.Code, w.Body.String())
+	}
+	var updated models.Copy
+	db.First(&updated, item.ID)
+	if updated.Status != models.CopyStatusWithdrawn {
+		t.Errorf("单册状态为 %q，期望 %q", updated.Status, models.CopyStatusWithdrawn)
+	}
+
+	w = serve(http.MethodGet, "/books/:id/copies", "/books/999/copies", GetBookCopies, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("查询不存在图书的单册返回 %d，期望 404", w.Code)
+	}
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize 导出时每批从数据库读取的行数，整表不会一次性载入内存
+const exportBatchSize = 500
+
+// startExport 写入下载响应头，响应体开始写出后无法再返回 JSON 错误
+func startExport(c *gin.Context, name, format string) {
+	contentType, ext := catalog.ExportContentType(format)
+	filename := fmt.Sprintf("%s-%s%s", name, time.Now().Format("20060102-150405"), ext)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// @Summary 导出图书
+// @Description 流式导出图书目录，过滤参数与图书列表一致（需 export:books 权限）
+// @Tags export
+// @Security ApiKeyAuth
+// @Produce text/csv,application/x-ndjson,application/marcxml+xml
+// @Param format query string false "导出格式: csv（默认）/ndjson/marcxml"
+// @Param title query string false "按书名模糊查询，支持全拼和首字母"
+// @Param author query string false "按作者模糊查询，支持全拼和首字母"
+// @Param summary query string false "按简介模糊查询"
+// @Param category query string false "按分类号筛选，包含其所有下级分类"
+// @Param tag query []string false "按标签筛选，可传多个，须同时满足" collectionFormat(multi)
+// @Param archived query string false "已归档图书: 默认不含，only 只看已归档，include 包含已归档"
+// @Success 200 {file} file "导出文件"
+// @Failure 400 {object} models.Response "不支持的导出格式"
+// @Router /api/admin/export/books [get]
+func ExportBooks(c *gin.Context) {
+	format := c.DefaultQuery("format", catalog.ExportFormatCSV)
+	if format != catalog.ExportFormatCSV && format != catalog.ExportFormatNDJSON && format != catalog.ExportFormatMARCXML {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "不支持的导出格式",
+		})
+		return
+	}
+
+	startExport(c, "books", format)
+	writer, err := catalog.NewBookWriter(c.Writer, format)
+	if err != nil {
+		log.Printf("图书导出失败: %v", err)
+		return
+	}
+
+	var batch []models.Book
+	query := applyBookFilters(config.DB.Model(&models.Book{}), c)
+	err = query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			fillCoverURL(&batch[i])
+			if err := writer.WriteBook(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}).Error
+	if err != nil {
+		// 已开始输出，只能中断响应，客户端收到的文件不完整
+		log.Printf("图书导出中断: %v", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("图书导出失败: %v", err)
+	}
+}
+
+// @Summary 导出借阅记录
+// @Description 流式导出全部借阅历史，附带用户名、书名和单册条码（需 export:records 权限）
+// @Tags export
+// @Security ApiKeyAuth
+// @Produce text/csv,application/x-ndjson
+// @Param format query string false "导出格式: csv（默认）/ndjson"
+// @Param user_id query uint false "用户ID"
+// @Param book_id query uint false "图书ID"
+// @Param status query string false "状态: borrowed/overdue/returned"
+// @Success 200 {file} file "导出文件"
+// @Failure 400 {object} models.Response "不支持的导出格式"
+// @Router /api/admin/export/records [get]
+func ExportBorrowRecords(c *gin.Context) {
+	format := c.DefaultQuery("format", catalog.ExportFormatCSV)
+	if format != catalog.ExportFormatCSV && format != catalog.ExportFormatNDJSON {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "不支持的导出格式",
+		})
+		return
+	}
+
+	query := config.DB.Model(&models.BorrowRecord{}).Preload("User").Preload("Book", withArchived).Preload("Copy")
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if bookID := c.Query("book_id"); bookID != "" {
+		query = query.Where("book_id = ?", bookID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	startExport(c, "borrow-records", format)
+	writer, err := catalog.NewRecordWriter(c.Writer, format)
+	if err != nil {
+		log.Printf("借阅记录导出失败: %v", err)
+		return
+	}
+
+	var batch []models.BorrowRecord
+	err = query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			fillCoverURL(batch[i].Book)
+			if err := writer.WriteRecord(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}).Error
+	if err != nil {
+		log.Printf("借阅记录导出中断: %v", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("借阅记录导出失败: %v", err)
+	}
+}
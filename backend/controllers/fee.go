@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFeeNotFound = errors.New("费用记录不存在")
+	ErrFeeSettled  = errors.New("费用已结清或已减免")
+	ErrOverpaid    = errors.New("缴费金额超过未结清金额")
+)
+
+// @Summary 查询我的费用
+// @Description 查询当前用户的费用明细与未结清余额（单位：分）
+// @Tags fees
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response "查询成功，data 包含 balance 与 fees"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/fees [get]
+func GetMyFees(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var fees []models.Fee
+	if err := config.DB.Preload("Payments").
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Find(&fees).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	balance, err := jobs.OutstandingBalance(config.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: gin.H{
+			"balance": balance,
+			"fees":    fees,
+		},
+	})
+}
+
+// @Summary 查询费用台账
+// @Description 按用户和状态查询费用（需 fees:manage 权限）
+// @Tags fees
+// @Security ApiKeyAuth
+// @Produce json
+// @Param user_id query uint false "用户ID"
+// @Param status query string false "状态: unpaid/paid/waived"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: created_at/amount，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，默认 desc"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.Fee}} "查询成功"
+// @Failure 400 {object} models.Response "分页或排序参数错误"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/fees [get]
+func GetFees(c *gin.Context) {
+	query := config.DB.Model(&models.Fee{})
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	result, err := paginate(c, query, feeSorts, func(f *models.Fee) uint { return f.ID })
+	if err != nil {
+		respondPageError(c, err)
+		return
+	}
+
+	fees := result.Items.([]models.Fee)
+	if err := preloadPage(config.DB.Preload("Payments").Preload("BorrowRecord"), fees, func(f *models.Fee) uint { return f.ID }); err != nil {
+		respondPageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: result,
+	})
+}
+
+// @Summary 登记赔偿费用
+// @Description 为借阅记录登记遗失或损坏赔偿（需 fees:manage 权限）
+// @Tags fees
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CreateFeeRequest true "费用信息"
+// @Success 200 {object} models.Response{data=models.Fee} "登记成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "借阅记录不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/fees [post]
+func CreateFee(c *gin.Context) {
+	var req models.CreateFeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var record models.BorrowRecord
+	if err := config.DB.First(&record, req.BorrowRecordID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "借阅记录不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	operatorID := c.GetUint("user_id")
+	fee := models.Fee{
+		UserID:         record.UserID,
+		BorrowRecordID: record.ID,
+		Type:           req.Type,
+		Amount:         req.Amount,
+		Status:         models.FeeStatusUnpaid,
+		Note:           req.Note,
+		OperatorID:     &operatorID,
+	}
+	if err := config.DB.Create(&fee).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "登记费用失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "登记费用成功",
+		Data: fee,
+	})
+}
+
+// @Summary 记录缴费
+// @Description 为费用记录一笔缴费，可分多次缴清（需 fees:manage 权限）
+// @Tags fees
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "费用ID"
+// @Param request body models.PayFeeRequest true "缴费信息"
+// @Success 200 {object} models.Response{data=models.Fee} "缴费成功"
+// @Failure 400 {object} models.Response "参数错误或金额超出"
+// @Failure 404 {object} models.Response "费用不存在"
+// @Failure 409 {object} models.Response "费用已结清或已减免"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/fees/{id}/payments [post]
+func PayFee(c *gin.Context) {
+	feeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的费用ID",
+		})
+		return
+	}
+
+	var req models.PayFeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	operatorID := c.GetUint("user_id")
+	var fee models.Fee
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fee, feeID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFeeNotFound
+			}
+			return err
+		}
+
+		if fee.Status != models.FeeStatusUnpaid {
+			return ErrFeeSettled
+		}
+		if req.Amount > fee.Outstanding() {
+			return ErrOverpaid
+		}
+
+		payment := models.FeePayment{
+			FeeID:      fee.ID,
+			Amount:     req.Amount,
+			OperatorID: operatorID,
+			Note:       req.Note,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		status := models.FeeStatusUnpaid
+		if fee.PaidAmount+req.Amount >= fee.Amount {
+			status = models.FeeStatusPaid
+		}
+		return tx.Model(&fee).Updates(map[string]interface{}{
+			"paid_amount": fee.PaidAmount + req.Amount,
+			"status":      status,
+			"operator_id": &operatorID,
+		}).Error
+	})
+
+	if err != nil {
+		respondFeeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "缴费成功",
+		Data: fee,
+	})
+}
+
+// @Summary 减免费用
+// @Description 减免一笔未结清的费用（需 fees:manage 权限）
+// @Tags fees
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "费用ID"
+// @Param request body models.WaiveFeeRequest false "减免备注"
+// @Success 200 {object} models.Response{data=models.Fee} "减免成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "费用不存在"
+// @Failure 409 {object} models.Response "费用已结清或已减免"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/fees/{id}/waive [post]
+func WaiveFee(c *gin.Context) {
+	feeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的费用ID",
+		})
+		return
+	}
+
+	var req models.WaiveFeeRequest
+	// 备注可选，允许空请求体
+	_ = c.ShouldBindJSON(&req)
+
+	operatorID := c.GetUint("user_id")
+	var fee models.Fee
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fee, feeID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFeeNotFound
+			}
+			return err
+		}
+
+		if fee.Status != models.FeeStatusUnpaid {
+			return ErrFeeSettled
+		}
+
+		updates := map[string]interface{}{
+			"status":      models.FeeStatusWaived,
+			"operator_id": &operatorID,
+		}
+		if req.Note != "" {
+			updates["note"] = req.Note
+		}
+		return tx.Model(&fee).Updates(updates).Error
+	})
+
+	if err != nil {
+		respondFeeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "减免成功",
+		Data: fee,
+	})
+}
+
+func respondFeeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrFeeNotFound):
+		c.JSON(http.StatusNotFound, models.Response{
+			Code: 404,
+			Msg:  "费用记录不存在",
+		})
+	case errors.Is(err, ErrFeeSettled):
+		c.JSON(http.StatusConflict, models.Response{
+			Code: 409,
+			Msg:  "费用已结清或已减免",
+		})
+	case errors.Is(err, ErrOverpaid):
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "缴费金额超过未结清金额",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "系统繁忙,请稍后再试",
+		})
+	}
+}
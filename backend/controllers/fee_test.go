@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+// createFee 为 user 登记一笔指定金额的逾期费用
+func createFee(t *testing.T, db *gorm.DB, user *models.User, amount, paid int64, status string) *models.Fee {
+	t.Helper()
+
+	book, _ := createBookWithCopies(t, db, fmt.Sprintf("图书%d", amount), 0)
+	returned := time.Now()
+	record := models.BorrowRecord{UserID: user.ID, BookID: book.ID, BorrowDate: returned.AddDate(0, 0, -40), ReturnDate: &returned, Status: models.BorrowStatusReturned}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	fee := models.Fee{UserID: user.ID, BorrowRecordID: record.ID, Type: models.FeeTypeOverdue, Amount: amount, PaidAmount: paid, Status: status}
+	if err := db.Create(&fee).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &fee
+}
+
+func TestPayFee(t *testing.T) {
+	db := setupTestDB(t)
+	reader := createUser(t, db, "reader", "user")
+	desk := createUser(t, db, "desk", "librarian")
+	fee := createFee(t, db, reader, 500, 0, models.FeeStatusUnpaid)
+
+	steps := []struct {
+		name       string
+		amount     int64
+		wantCode   int
+		wantPaid   int64
+		wantStatus string
+	}{
+		{name: "部分缴费", amount: 200, wantCode: http.StatusOK, wantPaid: 200, wantStatus: models.FeeStatusUnpaid},
+		{name: "超过未结清金额", amount: 400, wantCode: http.StatusBadRequest, wantPaid: 200, wantStatus: models.FeeStatusUnpaid},
+		{name: "缴清", amount: 300, wantCode: http.StatusOK, wantPaid: 500, wantStatus: models.FeeStatusPaid},
+		{name: "已结清后再缴费", amount: 100, wantCode: http.StatusConflict, wantPaid: 500, wantStatus: models.FeeStatusPaid},
+	}
+	for _, tt := range steps {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSON(http.MethodPost, "/fees/:id/payments", fmt.Sprintf("/fees/%d/payments", fee.ID),
+				fmt.Sprintf(`{"amount":%d}`, tt.amount), PayFee, map[string]interface{}{"user_id": desk.ID})
+			if w.Code != tt.wantCode {
+				t.Fatalf("缴费返回 %d，期望 %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			var got models.Fee
+			db.First(&got, fee.ID)
+			if got.PaidAmount != tt.wantPaid || got.Status != tt.wantStatus {
+				t.Errorf("费用为 %d/%s，期望 %d/%s", got.PaidAmount, got.Status, tt.wantPaid, tt.wantStatus)
+			}
+		})
+	}
+
+	var payments []models.FeePayment
+	db.Where("fee_id = ?", fee.ID).Order("id ASC").Find(&payments)
+	if len(payments) != 2 || payments[0].Amount != 200 || payments[1].Amount != 300 || payments[0].OperatorID != desk.ID {
+		t.Errorf("缴费流水为 %+v，期望两笔 200、300 且操作人为 %d", payments, desk.ID)
+	}
+
+	w := serveJSON(http.MethodPost, "/fees/:id/payments", "/fees/999/payments", `{"amount":100}`, PayFee, map[string]interface{}{"user_id": desk.ID})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("为不存在的费用缴费返回 %d，期望 404", w.Code)
+	}
+}
+
+func TestWaiveFee(t *testing.T) {
+	db := setupTestDB(t)
+	reader := createUser(t, db, "reader", "user")
+	desk := createUser(t, db, "desk", "librarian")
+
+	tests := []struct {
+		name       string
+		paid       int64
+		status     string
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "未缴费", status: models.FeeStatusUnpaid, wantCode: http.StatusOK, wantStatus: models.FeeStatusWaived},
+		{name: "部分缴费后减免余额", paid: 100, status: models.FeeStatusUnpaid, wantCode: http.StatusOK, wantStatus: models.FeeStatusWaived},
+		{name: "已结清", paid: 300, status: models.FeeStatusPaid, wantCode: http.StatusConflict, wantStatus: models.FeeStatusPaid},
+		{name: "已减免", status: models.FeeStatusWaived, wantCode: http.StatusConflict, wantStatus: models.FeeStatusWaived},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee := createFee(t, db, reader, 300, tt.paid, tt.status)
+
+			w := serveJSON(http.MethodPost, "/fees/:id/waive", fmt.Sprintf("/fees/%d/waive", fee.ID),
+				`{"note":"首次逾期"}`, WaiveFee, map[string]interface{}{"user_id": desk.ID})
+			if w.Code != tt.wantCode {
+				t.Fatalf("减免返回 %d，期望 %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			var got models.Fee
+			db.First(&got, fee.ID)
+			if got.Status != tt.wantStatus || got.PaidAmount != tt.paid {
+				t.Errorf("费用为 %d/%s，期望 %d/%s", got.PaidAmount, got.Status, tt.paid, tt.wantStatus)
+			}
+			if tt.wantCode == http.StatusOK && (got.Note != "首次逾期" || got.OperatorID == nil || *got.OperatorID != desk.ID) {
+				t.Errorf("减免备注为 %q、操作人为 %v，期望记录备注和操作人 %d", got.Note, got.OperatorID, desk.ID)
+			}
+		})
+	}
+
+	// 已减免的费用不能再缴费
+	fee := createFee(t, db, reader, 300, 0, models.FeeStatusWaived)
+	w := serveJSON(http.MethodPost, "/fees/:id/payments", fmt.Sprintf("/fees/%d/payments", fee.ID), `{"amount":100}`, PayFee, map[string]interface{}{"user_id": desk.ID})
+	if w.Code != http.StatusConflict {
+		t.Errorf("为已减免的费用缴费返回 %d，期望 409", w.Code)
+	}
+}
+
+func TestBorrowBookFineThreshold(t *testing.T) {
+	t.Setenv("FINE_BLOCK_THRESHOLD", "1000")
+
+	type fee struct {
+		amount, paid int64
+		status       string
+	}
+	tests := []struct {
+		name     string
+		fees     []fee
+		wantCode int
+	}{
+		{name: "无欠费", wantCode: http.StatusOK},
+		{name: "恰好等于限额", fees: []fee{{600, 0, models.FeeStatusUnpaid}, {400, 0, models.FeeStatusUnpaid}}, wantCode: http.StatusOK},
+		{name: "超过限额", fees: []fee{{600, 0, models.FeeStatusUnpaid}, {401, 0, models.FeeStatusUnpaid}}, wantCode: http.StatusForbidden},
+		{name: "部分缴费后低于限额", fees: []fee{{1500, 600, models.FeeStatusUnpaid}}, wantCode: http.StatusOK},
+		{name: "已减免和已结清的不计入", fees: []fee{{3000, 0, models.FeeStatusWaived}, {2000, 2000, models.FeeStatusPaid}}, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			user := createUser(t, db, "reader", "user")
+			for _, f := range tt.fees {
+				createFee(t, db, user, f.amount, f.paid, f.status)
+			}
+			book, _ := createBookWithCopies(t, db, "围城", 1)
+
+			w := serveJSON(http.MethodPost, "/borrows", "/borrows", fmt.Sprintf(`{"id":%d}`, book.ID), BorrowBook,
+				map[string]interface{}{"user_id": user.ID})
+			if w.Code != tt.wantCode {
+				t.Fatalf("借书返回 %d，期望 %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+
+			var count int64
+			db.Model(&models.BorrowRecord{}).Where("user_id = ? AND book_id = ?", user.ID, book.ID).Count(&count)
+			want := int64(0)
+			if tt.wantCode == http.StatusOK {
+				want = 1
+			}
+			if count != want {
+				t.Errorf("创建了 %d 条借阅记录，期望 %d 条", count, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用内存 SQLite 替换 config.DB，开启外键约束并按正式环境的迁移建表
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=foreign_keys(1)", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := config.Migrate(db); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+
+	old := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = old
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// serve 以指定的上下文值调用处理函数，模拟 AuthRequired 写入的用户信息
+func serve(method, route, target string, handler gin.HandlerFunc, values map[string]interface{}) *httptest.ResponseRecorder {
+	return serveJSON(method, route, target, "", handler, values)
+}
+
+// serveJSON 与 serve 相同，请求体为 JSON
+func serveJSON(method, route, target, body string, handler gin.HandlerFunc, values map[string]interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return serveRequest(route, req, values, handler)
+}
+
+// serveForm 与 serve 相同，请求体为表单
+func serveForm(method, route, target string, form url.Values, handler gin.HandlerFunc, values map[string]interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serveRequest(route, req, values, handler)
+}
+
+// serveRequest 将 req 交给注册在 route 上的处理链，values 在处理前写入上下文
+func serveRequest(route string, req *http.Request, values map[string]interface{}, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(req.Method, route, append([]gin.HandlerFunc{func(c *gin.Context) {
+		for k, v := range values {
+			c.Set(k, v)
+		}
+		c.Next()
+	}}, handlers...)...)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// responseMsg 解析响应中的提示信息
+func responseMsg(t *testing.T, body []byte) string {
+	t.Helper()
+
+	var resp models.Response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return resp.Msg
+}
+
+// createUser 创建指定角色的用户
+func createUser(t *testing.T, db *gorm.DB, username, role string) *models.User {
+	t.Helper()
+
+	user := models.User{Username: username, Password: "x", Role: role}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return &user
+}
+
+// createBookWithCopies 创建一本带 n 个在架单册的图书
+func createBookWithCopies(t *testing.T, db *gorm.DB, title string, n int) (*models.Book, []models.Copy) {
+	t.Helper()
+
+	book := models.Book{Title: title, Author: "佚名", CoverPath: models.DefaultCoverPath, InitialStock: n, TotalStock: n, Stock: n}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatalf("创建图书失败: %v", err)
+	}
+	copies := make([]models.Copy, n)
+	for i := range copies {
+		copies[i] = models.Copy{BookID: book.ID, Barcode: fmt.Sprintf("B%d-%d", book.ID, i+1), Status: models.CopyStatusAvailable}
+		if err := db.Omit("Book").Create(&copies[i]).Error; err != nil {
+			t.Fatalf("创建单册失败: %v", err)
+		}
+	}
+	return &book, copies
+}
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 导入 MARC 书目
+// @Description 上传 MARC21（ISO 2709）或 MARCXML 文件批量导入图书，默认试运行只校验不入库，mode=commit 时逐条入库（需 books:import 权限）
+// @Tags books
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "MARC 文件"
+// @Param format formData string false "文件格式: auto/marc21/marcxml，默认 auto"
+// @Param mode formData string false "dry-run（默认）或 commit"
+// @Param atomic formData bool false "为 true 时整批在同一事务中提交，任一记录无效则全部回滚"
+// @Param initial_stock formData int false "每种新书生成的单册数量，默认 0"
+// @Success 200 {object} models.Response{data=catalog.ImportReport} "导入完成，data 为逐条结果报告"
+// @Failure 400 {object} models.Response "参数错误或文件无法解析"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/import/marc [post]
+func ImportMARC(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "请上传 MARC 文件",
+		})
+		return
+	}
+
+	opts, ok := bindImportOptions(c)
+	if !ok {
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "读取上传文件失败",
+		})
+		return
+	}
+	defer file.Close()
+
+	reader, err := catalog.NewMARCReader(file, c.DefaultPostForm("format", catalog.MARCFormatAuto))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "不支持的文件格式",
+		})
+		return
+	}
+
+	report, err := catalog.ImportMARC(config.DB, reader, opts)
+	if err != nil {
+		log.Printf("MARC 导入中断: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "文件解析中断: " + err.Error(),
+			Data: report,
+		})
+		return
+	}
+
+	respondImportReport(c, report)
+}
+
+// @Summary 表格批量导入图书
+// @Description 上传 CSV 或 XLSX 表格批量导入图书，首行为表头：title、author、summary、initial_stock、isbn、cover（也可用中文列名 书名、作者、简介、初始库存、封面）。cover 列填写随附 zip 压缩包中的封面文件名。默认试运行，mode=commit 时入库，atomic=true 时整批在同一事务中提交（需 books:import 权限）
+// @Tags books
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV 或 XLSX 表格"
+// @Param covers formData file false "封面 zip 压缩包"
+// @Param mode formData string false "dry-run（默认）或 commit"
+// @Param atomic formData bool false "为 true 时整批在同一事务中提交，任一行无效则全部回滚；否则逐行提交"
+// @Param initial_stock formData int false "initial_stock 列为空时的单册数量，默认 0"
+// @Success 200 {object} models.Response{data=catalog.ImportReport} "导入完成，data 为逐行结果报告，index 为表格行号"
+// @Failure 400 {object} models.Response "参数错误或文件无法解析"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/books/import [post]
+func ImportBooks(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "请上传 CSV 或 XLSX 文件",
+		})
+		return
+	}
+
+	opts, ok := bindImportOptions(c)
+	if !ok {
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "读取上传文件失败",
+		})
+		return
+	}
+	defer file.Close()
+
+	rows, err := catalog.ReadBookSheet(file, fileHeader.Filename, opts.InitialStock)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "表格解析失败: " + err.Error(),
+		})
+		return
+	}
+
+	var covers *catalog.CoverArchive
+	if coversHeader, err := c.FormFile("covers"); err == nil {
+		coversFile, err := coversHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "读取上传文件失败",
+			})
+			return
+		}
+		defer coversFile.Close()
+
+		covers, err = catalog.OpenCoverArchive(coversFile, coversHeader.Size)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				Code: 400,
+				Msg:  "封面压缩包无法解析",
+			})
+			return
+		}
+	}
+
+	report, err := catalog.ImportRows(config.DB, rows, covers, opts)
+	if err != nil {
+		log.Printf("表格导入失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "导入失败",
+			Data: report,
+		})
+		return
+	}
+
+	respondImportReport(c, report)
+}
+
+func respondImportReport(c *gin.Context, report *catalog.ImportReport) {
+	msg := "导入完成"
+	switch {
+	case report.DryRun:
+		msg = "试运行完成，未写入数据"
+	case report.RolledBack:
+		msg = "存在无效记录，已全部回滚"
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  msg,
+		Data: report,
+	})
+}
+
+// bindImportOptions 解析 mode、atomic 与 initial_stock 表单参数，失败时已写入响应
+func bindImportOptions(c *gin.Context) (catalog.ImportOptions, bool) {
+	opts := catalog.ImportOptions{DryRun: true}
+
+	switch c.DefaultPostForm("mode", "dry-run") {
+	case "dry-run":
+	case "commit":
+		opts.DryRun = false
+	default:
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "mode 只能为 dry-run 或 commit",
+		})
+		return opts, false
+	}
+
+	if raw := c.PostForm("atomic"); raw != "" {
+		atomic, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				Code: 400,
+				Msg:  "atomic 须为 true 或 false",
+			})
+			return opts, false
+		}
+		opts.Atomic = atomic
+	}
+
+	if raw := c.PostForm("initial_stock"); raw != "" {
+		stock, err := strconv.Atoi(raw)
+		if err != nil || stock < 0 || stock > 100 {
+			c.JSON(http.StatusBadRequest, models.Response{
+				Code: 400,
+				Msg:  "initial_stock 须为 0-100 的整数",
+			})
+			return opts, false
+		}
+		opts.InitialStock = stock
+	}
+
+	return opts, true
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	ErrInvalidPageQuery = errors.New("分页参数错误")
+	ErrInvalidSort      = errors.New("不支持的排序字段")
+	ErrInvalidCursor    = errors.New("无效的游标")
+)
+
+// sortColumn 可排序字段，value 取出记录上的字段值用于生成游标
+type sortColumn[T any] struct {
+	column string
+	kind   string // string/int/time，决定游标中的值如何还原
+	value  func(item *T) interface{}
+}
+
+var bookSorts = map[string]sortColumn[models.Book]{
+	"title":      {"title", "string", func(b *models.Book) interface{} { return b.Title }},
+	"author":     {"author", "string", func(b *models.Book) interface{} { return b.Author }},
+	"created_at": {"created_at", "time", func(b *models.Book) interface{} { return b.CreatedAt }},
+	"stock":      {"stock", "int", func(b *models.Book) interface{} { return b.Stock }},
+}
+
+var recordSorts = map[string]sortColumn[models.BorrowRecord]{
+	"created_at":  {"created_at", "time", func(r *models.BorrowRecord) interface{} { return r.CreatedAt }},
+	"borrow_date": {"borrow_date", "time", func(r *models.BorrowRecord) interface{} { return r.BorrowDate }},
+}
+
+// overdueSorts 逾期记录额外支持按应还时间排序，逾期记录的 due_date 均不为空
+var overdueSorts = map[string]sortColumn[models.BorrowRecord]{
+	"due_date":    {"due_date", "time", func(r *models.BorrowRecord) interface{} { return r.DueDate }},
+	"created_at":  recordSorts["created_at"],
+	"borrow_date": recordSorts["borrow_date"],
+}
+
+var feeSorts = map[string]sortColumn[models.Fee]{
+	"created_at": {"created_at", "time", func(f *models.Fee) interface{} { return f.CreatedAt }},
+	"amount":     {"amount", "int", func(f *models.Fee) interface{} { return f.Amount }},
+}
+
+var userSorts = map[string]sortColumn[models.User]{
+	"username":   {"username", "string", func(u *models.User) interface{} { return u.Username }},
+	"created_at": {"created_at", "time", func(u *models.User) interface{} { return u.CreatedAt }},
+}
+
+// pageCursor 游标内容：排序方式、最后一条记录的排序字段值和 ID
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v,omitempty"`
+	ID    uint            `json:"id"`
+}
+
+func encodeCursor(cur pageCursor) string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var cur pageCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &cur); err != nil || cur.ID == 0 {
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// cursorValue 按字段类型还原游标中的排序值
+func cursorValue(kind string, raw json.RawMessage) (interface{}, error) {
+	switch kind {
+	case "string":
+		var v string
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case "int":
+		var v int64
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case "time":
+		var v time.Time
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	}
+	return nil, ErrInvalidCursor
+}
+
+// paginate 按 PageQuery 对查询排序、分页并统计总数，排序字段只允许白名单内的值
+// 未指定 sort 时按 id 倒序；排序字段相同时以 id 作为第二排序键，保证游标翻页不重不漏
+// query 只应包含过滤条件，需要预加载的关联在返回后另行加载，避免统计总数时触发预加载
+func paginate[T any](c *gin.Context, query *gorm.DB, sorts map[string]sortColumn[T], idOf func(*T) uint) (*models.PageResult, error) {
+	var pq models.PageQuery
+	if err := c.ShouldBindQuery(&pq); err != nil {
+		return nil, ErrInvalidPageQuery
+	}
+	if pq.PageSize == 0 {
+		pq.PageSize = defaultPageSize
+	}
+	if pq.PageSize > maxPageSize {
+		pq.PageSize = maxPageSize
+	}
+	if pq.Page == 0 {
+		pq.Page = 1
+	}
+
+	var sortCol *sortColumn[T]
+	if pq.Sort != "" {
+		col, ok := sorts[pq.Sort]
+		if !ok {
+			return nil, ErrInvalidSort
+		}
+		sortCol = &col
+	}
+
+	dir, op := "DESC", "<"
+	if pq.Order == "asc" || (pq.Order == "" && sortCol != nil && sortCol.kind == "string") {
+		dir, op = "ASC", ">"
+	}
+	sortKey := pq.Sort + ":" + dir
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	page := query.Session(&gorm.Session{})
+	if pq.Cursor != "" {
+		cur, err := decodeCursor(pq.Cursor)
+		if err != nil || cur.Sort != sortKey {
+			return nil, ErrInvalidCursor
+		}
+		if sortCol == nil {
+			page = page.Where(fmt.Sprintf("id %s ?", op), cur.ID)
+		} else {
+			v, err := cursorValue(sortCol.kind, cur.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			page = page.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", sortCol.column, op, sortCol.column, op), v, v, cur.ID)
+		}
+	} else {
+		page = page.Offset((pq.Page - 1) * pq.PageSize)
+	}
+
+	if sortCol != nil {
+		page = page.Order(sortCol.column + " " + dir)
+	}
+	page = page.Order("id " + dir)
+
+	// 多取一条判断是否还有下一页
+	items := make([]T, 0, pq.PageSize+1)
+	if err := page.Limit(pq.PageSize + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	result := &models.PageResult{
+		Total:    total,
+		PageSize: pq.PageSize,
+	}
+	if pq.Cursor == "" {
+		result.Page = pq.Page
+	}
+
+	if len(items) > pq.PageSize {
+		items = items[:pq.PageSize]
+		last := &items[len(items)-1]
+		cur := pageCursor{Sort: sortKey, ID: idOf(last)}
+		if sortCol != nil {
+			raw, err := json.Marshal(sortCol.value(last))
+			if err != nil {
+				return nil, err
+			}
+			cur.Value = raw
+		}
+		result.NextCursor = encodeCursor(cur)
+	}
+	result.Items = items
+
+	return result, nil
+}
+
+// preloadPage 为 paginate 取出的一页记录加载关联：按 ID 用带预加载的 query 重新查询，结果保持原有顺序
+func preloadPage[T any](query *gorm.DB, items []T, idOf func(*T) uint) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(items))
+	index := make(map[uint]int, len(items))
+	for i := range items {
+		ids[i] = idOf(&items[i])
+		index[ids[i]] = i
+	}
+
+	var loaded []T
+	if err := query.Find(&loaded, ids).Error; err != nil {
+		return err
+	}
+	for i := range loaded {
+		items[index[idOf(&loaded[i])]] = loaded[i]
+	}
+	return nil
+}
+
+// respondPageError 分页参数错误返回 400，其余返回 500
+func respondPageError(c *gin.Context, err error) {
+	if errors.Is(err, ErrInvalidPageQuery) || errors.Is(err, ErrInvalidSort) || errors.Is(err, ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.Response{
+		Code: 500,
+		Msg:  "数据库查询失败",
+	})
+}
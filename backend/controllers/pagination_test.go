@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+)
+
+// pageOf 解析分页响应，items 解析为 T 的切片
+func pageOf[T any](t *testing.T, body []byte) ([]T, string) {
+	t.Helper()
+
+	var resp struct {
+		Data struct {
+			Items      []T    `json:"items"`
+			NextCursor string `json:"next_cursor"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return resp.Data.Items, resp.Data.NextCursor
+}
+
+// walkPages 按游标依次请求全部分页，返回每页的记录
+func walkPages[T any](t *testing.T, route string, handler gin.HandlerFunc, query url.Values) [][]T {
+	t.Helper()
+
+	var pages [][]T
+	for {
+		w := serve(http.MethodGet, route, route+"?"+query.Encode(), handler, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("查询返回 %d: %s", w.Code, w.Body.String())
+		}
+		items, next := pageOf[T](t, w.Body.Bytes())
+		pages = append(pages, items)
+		if next == "" || len(pages) > 10 {
+			return pages
+		}
+		query.Set("cursor", next)
+	}
+}
+
+func TestGetOverdueRecordsPaginated(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "reader", Password: "x", Role: "user"}
+	book := models.Book{Title: "围城", Author: "钱锺书", CoverPath: models.DefaultCoverPath}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, days := range []int{3, 9, 1} {
+		due := now.AddDate(0, 0, -days)
+		record := models.BorrowRecord{UserID: user.ID, BookID: book.ID, BorrowDate: due.AddDate(0, 0, -30), DueDate: &due, Status: models.BorrowStatusOverdue}
+		if err := db.Create(&record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pages := walkPages[models.BorrowRecord](t, "/records/overdue", GetOverdueRecords,
+		url.Values{"page_size": {"2"}, "sort": {"due_date"}, "order": {"asc"}})
+	if len(pages) != 2 || len(pages[0]) != 2 || len(pages[1]) != 1 {
+		t.Fatalf("分页结果为 %v，期望 2 页共 3 条", pages)
+	}
+
+	var last time.Time
+	for _, page := range pages {
+		for _, r := range page {
+			if r.User == nil || r.Book == nil {
+				t.Errorf("记录 %d 未加载用户或图书", r.ID)
+			}
+			if r.DueDate.Before(last) {
+				t.Errorf("记录 %d 未按应还时间升序", r.ID)
+			}
+			last = *r.DueDate
+		}
+	}
+}
+
+func TestGetFeesPaginated(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "reader", Password: "x", Role: "user"}
+	book := models.Book{Title: "围城", Author: "钱锺书", CoverPath: models.DefaultCoverPath}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+	record := models.BorrowRecord{UserID: user.ID, BookID: book.ID, BorrowDate: time.Now(), Status: models.BorrowStatusReturned}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, amount := range []int64{300, 100, 500, 200, 400} {
+		fee := models.Fee{UserID: user.ID, BorrowRecordID: record.ID, Type: models.FeeTypeOverdue, Amount: amount, Status: models.FeeStatusUnpaid}
+		if err := db.Create(&fee).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []int64
+	}{
+		{name: "默认按 ID 倒序", query: url.Values{"page_size": {"2"}}, want: []int64{400, 200, 500, 100, 300}},
+		{name: "按金额降序", query: url.Values{"page_size": {"2"}, "sort": {"amount"}}, want: []int64{500, 400, 300, 200, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := walkPages[models.Fee](t, "/fees", GetFees, tt.query)
+			if len(pages) != 3 {
+				t.Fatalf("返回 %d 页，期望 3 页", len(pages))
+			}
+
+			var got []int64
+			for _, page := range pages {
+				for _, f := range page {
+					if f.BorrowRecord == nil {
+						t.Errorf("费用 %d 未加载借阅记录", f.ID)
+					}
+					got = append(got, f.Amount)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("金额为 %v，期望 %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("金额为 %v，期望 %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 8, 30, 0, 123456789, time.FixedZone("CST", 8*3600))
+
+	tests := []struct {
+		name  string
+		sort  string
+		kind  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "默认排序", sort: ":DESC"},
+		{name: "字符串", sort: "title:ASC", kind: "string", value: "围城", want: "围城"},
+		{name: "整数", sort: "stock:DESC", kind: "int", value: 42, want: int64(42)},
+		{name: "时间", sort: "created_at:DESC", kind: "time", value: at, want: at},
+		{name: "时间指针", sort: "due_date:ASC", kind: "time", value: &at, want: at},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := pageCursor{Sort: tt.sort, ID: 7}
+			if tt.value != nil {
+				raw, err := json.Marshal(tt.value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cur.Value = raw
+			}
+
+			got, err := decodeCursor(encodeCursor(cur))
+			if err != nil {
+				t.Fatalf("解码游标失败: %v", err)
+			}
+			if got.Sort != tt.sort || got.ID != 7 {
+				t.Errorf("游标解码为 %+v，期望 sort=%s id=7", got, tt.sort)
+			}
+			if tt.kind == "" {
+				return
+			}
+
+			v, err := cursorValue(tt.kind, got.Value)
+			if err != nil {
+				t.Fatalf("还原排序值失败: %v", err)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				if !v.(time.Time).Equal(want) {
+					t.Errorf("排序值为 %v，期望 %v", v, want)
+				}
+			} else if v != tt.want {
+				t.Errorf("排序值为 %#v，期望 %#v", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "非 base64", cursor: "!!!"},
+		{name: "非 JSON", cursor: base64.RawURLEncoding.EncodeToString([]byte("title"))},
+		{name: "缺少 ID", cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"title:ASC","v":"a"}`))},
+		{name: "带填充的 base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"s":":DESC","id":1}`))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) 返回 %v，期望 ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+
+	if _, err := cursorValue("int", json.RawMessage(`"abc"`)); err == nil {
+		t.Error("类型不符的排序值应返回错误")
+	}
+	if _, err := cursorValue("float", json.RawMessage(`1.5`)); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("未知字段类型返回 %v，期望 ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,334 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrReservationNotFound = errors.New("预约记录不存在")
+	ErrAlreadyReserved     = errors.New("已预约该书")
+	ErrAlreadyBorrowed     = errors.New("该书已在借，无需预约")
+	ErrBookAvailable       = errors.New("图书有库存，可直接借阅")
+)
+
+// fillQueuePositions 计算排队中预约的位置（同一本书内按 id 先后）
+func fillQueuePositions(db *gorm.DB, reservations []models.Reservation) error {
+	for i := range reservations {
+		r := &reservations[i]
+		if r.Status != models.ReservationStatusWaiting {
+			continue
+		}
+
+		var ahead int64
+		if err := db.Model(&models.Reservation{}).
+			Where("book_id = ? AND status = ? AND id < ?", r.BookID, models.ReservationStatusWaiting, r.ID).
+			Count(&ahead).Error; err != nil {
+			return err
+		}
+		r.Position = int(ahead) + 1
+	}
+	return nil
+}
+
+// @Summary 预约图书
+// @Description 图书无可借库存时加入预约队列，归还后按先后顺序为读者保留
+// @Tags reservations
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.FindBookRequest true "预约请求"
+// @Success 200 {object} models.Response{data=models.Reservation} "预约成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "图书不存在"
+// @Failure 409 {object} models.Response "已预约、已在借或图书有库存"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/reservations [post]
+func PlaceReservation(c *gin.Context) {
+	var req models.FindBookRequest
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		log.Println("严重错误: 上下文中没有获取到 user_id")
+		c.JSON(http.StatusUnauthorized, models.Response{
+			Code: 401,
+			Msg:  "用户未登录或认证失效",
+		})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var reservation models.Reservation
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var book models.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, req.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Reservation{}).
+			Where("user_id = ? AND book_id = ? AND status IN ?", userID, book.ID, activeReservationStatuses).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyReserved
+		}
+
+		if err := tx.Model(&models.BorrowRecord{}).
+			Where("user_id = ? AND book_id = ? AND status IN ?", userID, book.ID, activeBorrowStatuses).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyBorrowed
+		}
+
+		// Stock 只统计在架可借的单册，保留中的不算
+		if book.Stock > 0 {
+			return ErrBookAvailable
+		}
+
+		reservation = models.Reservation{
+			UserID: userID,
+			BookID: book.ID,
+			Status: models.ReservationStatusWaiting,
+		}
+		if err := tx.Omit("User", "Book").Create(&reservation).Error; err != nil {
+			return err
+		}
+
+		reservations := []models.Reservation{reservation}
+		if err := fillQueuePositions(tx, reservations); err != nil {
+			return err
+		}
+		reservation = reservations[0]
+		return nil
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrBookNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "图书不存在",
+			})
+			return
+		}
+		if errors.Is(err, ErrAlreadyReserved) || errors.Is(err, ErrAlreadyBorrowed) || errors.Is(err, ErrBookAvailable) {
+			c.JSON(http.StatusConflict, models.Response{
+				Code: 409,
+				Msg:  err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "系统繁忙,请稍后再试",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "预约成功",
+		Data: reservation,
+	})
+}
+
+// @Summary 取消预约
+// @Description 取消自己排队中或保留中的预约，保留中的图书会顺延给下一位
+// @Tags reservations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "预约ID"
+// @Success 200 {object} models.Response "取消成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "预约不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/reservations/{id} [delete]
+func CancelReservation(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		log.Println("严重错误: 上下文中没有获取到 user_id")
+		c.JSON(http.StatusUnauthorized, models.Response{
+			Code: 401,
+			Msg:  "用户未登录或认证失效",
+		})
+		return
+	}
+
+	reservationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的预约ID",
+		})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var reservation models.Reservation
+		if err := tx.Where("id = ? AND user_id = ? AND status IN ?", reservationID, userID, activeReservationStatuses).
+			First(&reservation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReservationNotFound
+			}
+			return err
+		}
+
+		// 先锁图书行，与借还书保持相同的加锁顺序
+		var book models.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, reservation.BookID).Error; err != nil {
+			return err
+		}
+
+		// 加锁前预约可能已被借书兑现或过期顺延，锁定后重新读取，保留的单册以此时为准
+		reservation = models.Reservation{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ? AND status IN ?", reservationID, userID, activeReservationStatuses).
+			First(&reservation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReservationNotFound
+			}
+			return err
+		}
+
+		if err := tx.Model(&reservation).Update("status", models.ReservationStatusCancelled).Error; err != nil {
+			return err
+		}
+		if err := jobs.ReleaseHold(tx, &reservation); err != nil {
+			return err
+		}
+
+		return jobs.PromoteHolds(tx, &book, time.Now(), config.HoldPickupWindow())
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrReservationNotFound) {
+			c.JSON(http.StatusNotFound, models.Response{
+				Code: 404,
+				Msg:  "未找到该预约或已失效",
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "系统繁忙,请稍后再试",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "取消预约成功",
+	})
+}
+
+// @Summary 查询我的预约
+// @Description 查询当前用户仍有效的预约及排队位置
+// @Tags reservations
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.Reservation} "查询成功"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/reservations [get]
+func GetMyReservations(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var reservations []models.Reservation
+	if err := config.DB.Preload("Book", withArchived).
+		Where("user_id = ? AND status IN ?", userID, activeReservationStatuses).
+		Order("id ASC").
+		Find(&reservations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	if err := fillQueuePositions(config.DB, reservations); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+	for i := range reservations {
+		fillCoverURL(reservations[i].Book)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: reservations,
+	})
+}
+
+// @Summary 查询图书预约队列
+// @Description 查询指定图书仍有效的预约队列，附带用户信息（需 records:read 权限）
+// @Tags reservations
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "图书ID"
+// @Success 200 {object} models.Response{data=[]models.Reservation} "查询成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/books/{id}/reservations [get]
+func GetBookReservations(c *gin.Context) {
+	bookID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的图书ID",
+		})
+		return
+	}
+
+	var reservations []models.Reservation
+	if err := config.DB.Preload("User").
+		Where("book_id = ? AND status IN ?", bookID, activeReservationStatuses).
+		Order("id ASC").
+		Find(&reservations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	if err := fillQueuePositions(config.DB, reservations); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: reservations,
+	})
+}
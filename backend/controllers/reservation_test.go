@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestCancelReservation(t *testing.T) {
+	db := setupTestDB(t)
+	book, copies := createBookWithCopies(t, db, "围城", 1)
+	first := createUser(t, db, "first", "user")
+	second := createUser(t, db, "second", "user")
+
+	now := time.Now()
+	expires := now.Add(72 * time.Hour)
+	ready := models.Reservation{UserID: first.ID, BookID: book.ID, Status: models.ReservationStatusReady, CopyID: &copies[0].ID, ReadyAt: &now, ExpiresAt: &expires}
+	waiting := models.Reservation{UserID: second.ID, BookID: book.ID, Status: models.ReservationStatusWaiting}
+	for _, r := range []*models.Reservation{&ready, &waiting} {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Model(&copies[0]).Update("status", models.CopyStatusOnHold)
+	db.Model(book).Updates(map[string]interface{}{"stock": 0})
+
+	cancel := func(r *models.Reservation, userID uint) int {
+		target := fmt.Sprintf("/reservations/%d", r.ID)
+		return serve(http.MethodDelete, "/reservations/:id", target, CancelReservation, map[string]interface{}{"user_id": userID}).Code
+	}
+
+	if code := cancel(&ready, second.ID); code != http.StatusNotFound {
+		t.Errorf("取消他人的预约返回 %d，期望 404", code)
+	}
+	if code := cancel(&ready, first.ID); code != http.StatusOK {
+		t.Fatalf("取消保留中的预约返回 %d", code)
+	}
+
+	// 保留的单册顺延给排队的下一位
+	var next models.Reservation
+	db.First(&next, waiting.ID)
+	if next.Status != models.ReservationStatusReady || next.CopyID == nil || *next.CopyID != copies[0].ID {
+		t.Errorf("下一位预约为 %s/%v，期望保留单册 %d", next.Status, next.CopyID, copies[0].ID)
+	}
+	var item models.Copy
+	db.First(&item, copies[0].ID)
+	if item.Status != models.CopyStatusOnHold {
+		t.Errorf("单册状态为 %s，期望 %s", item.Status, models.CopyStatusOnHold)
+	}
+
+	// 已兑现的预约不能再取消，状态保持不变
+	db.Model(&next).Update("status", models.ReservationStatusFulfilled)
+	if code := cancel(&next, second.ID); code != http.StatusNotFound {
+		t.Errorf("取消已兑现的预约返回 %d，期望 404", code)
+	}
+	db.First(&next, waiting.ID)
+	if next.Status != models.ReservationStatusFulfilled {
+		t.Errorf("已兑现的预约被改为 %s", next.Status)
+	}
+}
+
+func TestReturnPromotesHold(t *testing.T) {
+	db := setupTestDB(t)
+	book, copies := createBookWithCopies(t, db, "围城", 1)
+	borrower := createUser(t, db, "borrower", "user")
+	holder := createUser(t, db, "holder", "user")
+	other := createUser(t, db, "other", "user")
+
+	borrow := func(userID uint, body string) int {
+		return serveJSON(http.MethodPost, "/borrows", "/borrows", body, BorrowBook, map[string]interface{}{"user_id": userID}).Code
+	}
+	byID := fmt.Sprintf(`{"id":%d}`, book.ID)
+	byBarcode := fmt.Sprintf(`{"barcode":%q}`, copies[0].Barcode)
+
+	if code := borrow(borrower.ID, byID); code != http.StatusOK {
+		t.Fatalf("借书返回 %d", code)
+	}
+	waiting := models.Reservation{UserID: holder.ID, BookID: book.ID, Status: models.ReservationStatusWaiting}
+	if err := db.Create(&waiting).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveJSON(http.MethodPost, "/borrows/return", "/borrows/return", byID, ReturnBook, map[string]interface{}{"user_id": borrower.ID})
+	if w.Code != http.StatusOK {
+		t.Fatalf("还书返回 %d: %s", w.Code, w.Body.String())
+	}
+
+	// 归还的单册保留给队首的预约，不计入库存
+	var hold models.Reservation
+	db.First(&hold, waiting.ID)
+	if hold.Status != models.ReservationStatusReady || hold.CopyID == nil || *hold.CopyID != copies[0].ID {
+		t.Fatalf("预约为 %s/%v，期望保留单册 %d", hold.Status, hold.CopyID, copies[0].ID)
+	}
+	if hold.ExpiresAt == nil || hold.ExpiresAt.Before(time.Now()) {
+		t.Errorf("取书期限为 %v，期望在当前时间之后", hold.ExpiresAt)
+	}
+	var item models.Copy
+	db.First(&item, copies[0].ID)
+	if item.Status != models.CopyStatusOnHold {
+		t.Errorf("单册状态为 %s，期望 %s", item.Status, models.CopyStatusOnHold)
+	}
+	var got models.Book
+	db.First(&got, book.ID)
+	if got.Stock != 0 {
+		t.Errorf("库存为 %d，期望 0", got.Stock)
+	}
+
+	// 取书期限内其他读者按图书或扫码都借不到
+	for _, body := range []string{byID, byBarcode} {
+		if code := borrow(other.ID, body); code != http.StatusConflict {
+			t.Errorf("其他读者借阅保留中的图书 %s 返回 %d，期望 409", body, code)
+		}
+	}
+	if code := borrow(borrower.ID, byID); code != http.StatusConflict {
+		t.Errorf("原借阅人再次借阅返回 %d，期望 409", code)
+	}
+
+	if code := borrow(holder.ID, byBarcode); code != http.StatusOK {
+		t.Fatalf("预约读者取书返回 %d", code)
+	}
+	db.First(&hold, waiting.ID)
+	if hold.Status != models.ReservationStatusFulfilled {
+		t.Errorf("预约状态为 %s，期望 %s", hold.Status, models.ReservationStatusFulfilled)
+	}
+	db.First(&item, copies[0].ID)
+	if item.Status != models.CopyStatusOnLoan {
+		t.Errorf("单册状态为 %s，期望 %s", item.Status, models.CopyStatusOnLoan)
+	}
+}
@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var ErrSelfRoleChange = errors.New("不能修改自己的角色")
+
+// respondRoleError 将角色相关错误映射为 HTTP 响应
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrRoleNotFound), errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.Response{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, auth.ErrUnknownPermission):
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, auth.ErrRoleExists), errors.Is(err, auth.ErrRoleBuiltin), errors.Is(err, auth.ErrRoleInUse),
+		errors.Is(err, auth.ErrAdminRoleFixed), errors.Is(err, ErrSelfRoleChange):
+		c.JSON(http.StatusConflict, models.Response{
+			Code: 409,
+			Msg:  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "角色操作失败",
+		})
+	}
+}
+
+// @Summary 查询权限列表
+// @Description 列出系统定义的全部权限（需 roles:manage 权限）
+// @Tags roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.Permission} "查询成功"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/permissions [get]
+func GetPermissions(c *gin.Context) {
+	var perms []models.Permission
+	if err := config.DB.Order("code ASC").Find(&perms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: perms,
+	})
+}
+
+// @Summary 查询角色列表
+// @Description 列出全部角色及其权限（需 roles:manage 权限）
+// @Tags roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.Role} "查询成功"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/roles [get]
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("id ASC").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: roles,
+	})
+}
+
+// @Summary 创建角色
+// @Description 新增自定义角色并设置权限（需 roles:manage 权限）
+// @Tags roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.RoleRequest true "角色名、说明和权限列表"
+// @Success 200 {object} models.Response{data=models.Role} "创建成功"
+// @Failure 400 {object} models.Response "参数错误或权限不存在"
+// @Failure 409 {object} models.Response "角色已存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/roles [post]
+func CreateRole(c *gin.Context) {
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	role := models.Role{Name: req.Name, Description: req.Description}
+	if err := auth.SaveRole(config.DB, &role, req.Permissions); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "角色创建成功",
+		Data: role,
+	})
+}
+
+// @Summary 修改角色
+// @Description 修改角色说明和权限，角色名不可修改，admin 角色不能修改；修改后立即对该角色的所有用户生效（需 roles:manage 权限）
+// @Tags roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "角色ID"
+// @Param request body models.RoleRequest true "说明和权限列表"
+// @Success 200 {object} models.Response{data=models.Role} "修改成功"
+// @Failure 400 {object} models.Response "参数错误或权限不存在"
+// @Failure 404 {object} models.Response "角色不存在"
+// @Failure 409 {object} models.Response "admin 角色不能修改"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/roles/{id} [put]
+func UpdateRole(c *gin.Context) {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的角色ID",
+		})
+		return
+	}
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = auth.ErrRoleNotFound
+		}
+		respondRoleError(c, err)
+		return
+	}
+	if req.Name != "" && req.Name != role.Name {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "角色名不可修改",
+		})
+		return
+	}
+
+	role.Description = req.Description
+	if err := auth.SaveRole(config.DB, &role, req.Permissions); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "角色修改成功",
+		Data: role,
+	})
+}
+
+// @Summary 删除角色
+// @Description 删除自定义角色，内置角色和仍有用户使用的角色不能删除（需 roles:manage 权限）
+// @Tags roles
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "角色ID"
+// @Success 200 {object} models.Response "删除成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "角色不存在"
+// @Failure 409 {object} models.Response "内置角色或仍在使用"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/roles/{id} [delete]
+func DeleteRole(c *gin.Context) {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的角色ID",
+		})
+		return
+	}
+
+	if err := auth.DeleteRole(config.DB, roleID); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "角色已删除",
+	})
+}
+
+// @Summary 分配角色
+// @Description 修改用户的角色，不能修改自己的角色；该用户已建立的会话和已签发的令牌立即失效，重新登录后按新角色生效（需 roles:manage 权限）
+// @Tags roles
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "用户ID"
+// @Param request body models.SetUserRoleRequest true "角色名"
+// @Success 200 {object} models.Response{data=models.User} "分配成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "用户或角色不存在"
+// @Failure 409 {object} models.Response "不能修改自己的角色"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/users/{id}/role [put]
+func SetUserRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的用户ID",
+		})
+		return
+	}
+
+	var req models.SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	if uint(userID) == c.GetUint("user_id") {
+		respondRoleError(c, ErrSelfRoleChange)
+		return
+	}
+	exists, err := auth.RoleExists(config.DB, req.Role)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	if !exists {
+		respondRoleError(c, auth.ErrRoleNotFound)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrUserNotFound
+		}
+		respondRoleError(c, err)
+		return
+	}
+	if err := config.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	user.Role = req.Role
+	// 会话和令牌中缓存了角色，吊销后用户重新登录即按新角色生效
+	if err := revokeUserAccess(user.ID); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "角色分配成功",
+		Data: user,
+	})
+}
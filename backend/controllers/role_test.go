@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/middleware"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+)
+
+// serveGuarded 与 serveJSON 相同，处理函数前按路由配置加上 RequirePermission(perm)
+func serveGuarded(perm, method, route, target, body string, handler gin.HandlerFunc, values map[string]interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return serveRequest(route, req, values, middleware.RequirePermission(perm), handler)
+}
+
+func TestRequirePermissionBuiltinRoles(t *testing.T) {
+	db := setupTestDB(t)
+	if err := auth.SeedRoles(db); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+	auth.InvalidatePermissions()
+	t.Cleanup(auth.InvalidatePermissions)
+
+	reader := createUser(t, db, "reader", auth.RoleUser)
+	var librarianRole models.Role
+	if err := db.Where("name = ?", "librarian").First(&librarianRole).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		name    string
+		perm    string
+		method  string
+		route   string
+		target  string
+		body    string
+		handler gin.HandlerFunc
+	}{
+		{"查询用户", auth.PermUsersManage, http.MethodGet, "/users", "/users", "", GetUsers},
+		{"禁用用户", auth.PermUsersManage, http.MethodPost, "/users/:id/disable", fmt.Sprintf("/users/%d/disable", reader.ID), "", DisableUser},
+		{"分配管理员角色", auth.PermRolesManage, http.MethodPut, "/users/:id/role", fmt.Sprintf("/users/%d/role", reader.ID), `{"role":"admin"}`, SetUserRole},
+		{
+			"给馆员角色加上 roles:manage", auth.PermRolesManage, http.MethodPut, "/roles/:id", fmt.Sprintf("/roles/%d", librarianRole.ID),
+			fmt.Sprintf(`{"permissions":[%q]}`, auth.PermRolesManage), UpdateRole,
+		},
+	}
+	for _, role := range []string{"librarian", "cataloguer", auth.RoleUser} {
+		for _, tt := range requests {
+			t.Run(role+"/"+tt.name, func(t *testing.T) {
+				w := serveGuarded(tt.perm, tt.method, tt.route, tt.target, tt.body, tt.handler,
+					map[string]interface{}{"user_id": uint(9999), "role": role})
+				if w.Code != http.StatusForbidden {
+					t.Fatalf("返回 %d，期望 403: %s", w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+
+	// 被拒绝的请求没有产生任何修改
+	var got models.User
+	db.First(&got, reader.ID)
+	if got.Role != auth.RoleUser || got.DisabledAt != nil {
+		t.Errorf("读者被修改为 %s/%v", got.Role, got.DisabledAt)
+	}
+	if ok, _ := auth.RoleHasPermission(db, "librarian", auth.PermRolesManage); ok {
+		t.Error("librarian 角色被加上了 roles:manage 权限")
+	}
+
+	w := serveGuarded(auth.PermUsersManage, http.MethodGet, "/users", "/users", "", GetUsers,
+		map[string]interface{}{"user_id": uint(9999), "role": auth.RoleAdmin})
+	if w.Code != http.StatusOK {
+		t.Errorf("管理员查询用户返回 %d，期望 200", w.Code)
+	}
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary 全文检索图书
+// @Description 同时检索书名、作者和简介，按相关度排序，highlights 中命中词以 <mark> 标记
+// @Tags books
+// @Security ApiKeyAuth
+// @Produce json
+// @Param q query string true "检索词"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]search.BookHit}} "查询成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 500 {object} models.Response "检索失败"
+// @Router /api/books/search [get]
+func SearchBooks(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "检索词不能为空",
+		})
+		return
+	}
+
+	var pq models.PageQuery
+	if err := c.ShouldBindQuery(&pq); err != nil {
+		respondPageError(c, ErrInvalidPageQuery)
+		return
+	}
+	if pq.Page == 0 {
+		pq.Page = 1
+	}
+	if pq.PageSize == 0 {
+		pq.PageSize = defaultPageSize
+	}
+
+	result, err := search.Books.Search(q, (pq.Page-1)*pq.PageSize, pq.PageSize)
+	if err != nil {
+		log.Printf("图书检索失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "检索失败",
+		})
+		return
+	}
+
+	ids := make([]uint, 0, len(result.Hits))
+	for _, h := range result.Hits {
+		ids = append(ids, h.BookID)
+	}
+	var books []models.Book
+	if len(ids) > 0 {
+		if err := config.DB.Where("id IN ?", ids).Find(&books).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "数据库查询失败",
+			})
+			return
+		}
+	}
+	fillBookCovers(books)
+	byID := make(map[uint]models.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+
+	// 按相关度顺序组装结果，跳过索引中已不存在的图书
+	hits := make([]search.BookHit, 0, len(result.Hits))
+	for _, h := range result.Hits {
+		book, ok := byID[h.BookID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, search.BookHit{
+			Book:       book,
+			Score:      h.Score,
+			Highlights: h.Highlights,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: models.PageResult{
+			Items:    hits,
+			Total:    result.Total,
+			Page:     pq.Page,
+			PageSize: pq.PageSize,
+		},
+	})
+}
+
+// @Summary 搜索联想
+// @Description 输入时按前缀联想书名和作者，支持拼音全拼和首字母（如 hlm、hongloumeng）；前缀索引存放在 Redis（不可用时在进程内存），图书增删改后自动重建
+// @Tags books
+// @Security ApiKeyAuth
+// @Produce json
+// @Param q query string true "输入前缀"
+// @Param limit query int false "最多返回条数，默认 10，最大 20"
+// @Success 200 {object} models.Response{data=[]models.BookSuggestion} "查询成功"
+// @Failure 500 {object} models.Response "联想查询失败"
+// @Router /api/books/suggest [get]
+func SuggestBooks(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	if limit > 20 {
+		limit = 20
+	}
+
+	suggestions, err := search.Suggestions.Suggest(c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "联想查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: suggestions,
+	})
+}
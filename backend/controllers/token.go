@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// respondTokenError 令牌无效、过期、已吊销或账号被禁用返回 401，未配置签名密钥返回 503，其余为服务端错误
+func respondTokenError(c *gin.Context, err error) {
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTokenExpired) || errors.Is(err, auth.ErrTokenRevoked) ||
+		errors.Is(err, auth.ErrAccountDisabled) {
+		c.JSON(http.StatusUnauthorized, models.Response{
+			Code: 401,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, auth.ErrTokensOff) {
+		c.JSON(http.StatusServiceUnavailable, models.Response{
+			Code: 503,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.Response{
+		Code: 500,
+		Msg:  "令牌处理失败",
+	})
+}
+
+// @Summary 获取令牌
+// @Description 用户名密码换取访问令牌和刷新令牌，供无法使用 Cookie 的客户端（移动端、脚本）调用接口
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.LoginRequest true "登录请求"
+// @Success 200 {object} models.Response{data=models.TokenPair} "签发成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 403 {object} models.Response "认证失败或账号已被禁用"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Failure 503 {object} models.Response "未配置 JWT_KEYS，令牌认证未启用"
+// @Router /api/auth/token [post]
+func IssueToken(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	user, ok := checkCredentials(c, &req)
+	if !ok {
+		return
+	}
+
+	pair, err := auth.Tokens.Issue(user.ID, user.Role, time.Now())
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "令牌签发成功",
+		Data: pair,
+	})
+}
+
+// @Summary 刷新令牌
+// @Description 用刷新令牌换取新的一对令牌；刷新令牌只能使用一次，使用后即被吊销，角色按当前用户信息重新读取，账号被禁用时拒绝
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} models.Response{data=models.TokenPair} "刷新成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 401 {object} models.Response "刷新令牌无效、过期或已吊销"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Failure 503 {object} models.Response "未配置 JWT_KEYS，令牌认证未启用"
+// @Router /api/auth/token/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	claims, err := auth.Tokens.Parse(req.RefreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondTokenError(c, auth.ErrInvalidToken)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "查询数据库失败",
+		})
+		return
+	}
+	if user.DisabledAt != nil {
+		respondTokenError(c, auth.ErrAccountDisabled)
+		return
+	}
+
+	// 先吊销旧的刷新令牌，只有本次请求完成吊销时才签发，避免同一刷新令牌被并发重复使用
+	if err := auth.Consume(claims); err != nil {
+		respondTokenError(c, err)
+		return
+	}
+	pair, err := auth.Tokens.Issue(user.ID, user.Role, time.Now())
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "令牌刷新成功",
+		Data: pair,
+	})
+}
+
+// @Summary 吊销令牌
+// @Description 吊销刷新令牌，之后不能再用它换取新令牌；已签发的访问令牌在有效期内仍可用，需一并失效时调用登出接口
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "刷新令牌"
+// @Success 200 {object} models.Response "吊销成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 401 {object} models.Response "刷新令牌无效或已过期"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Failure 503 {object} models.Response "未配置 JWT_KEYS，令牌认证未启用"
+// @Router /api/auth/token/revoke [post]
+func RevokeToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	claims, err := auth.Tokens.Parse(req.RefreshToken, auth.TokenTypeRefresh)
+	// 重复吊销视为成功
+	if errors.Is(err, auth.ErrTokenRevoked) {
+		c.JSON(http.StatusOK, models.Response{
+			Code: 200,
+			Msg:  "令牌已吊销",
+		})
+		return
+	}
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+	if err := auth.Revoke(claims); err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "令牌已吊销",
+	})
+}
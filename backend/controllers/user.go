@@ -0,0 +1,467 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
+	"github.com/Dailiduzhou/library_manage_sys/middleware"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSelfDisable   = errors.New("不能禁用自己的账号")
+	ErrWrongPassword = errors.New("原密码错误")
+	ErrSamePassword  = errors.New("新密码不能与原密码相同")
+)
+
+// respondUserError 将用户管理相关错误映射为 HTTP 响应
+func respondUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.Response{
+			Code: 404,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, utils.ErrPasswordTooShort), errors.Is(err, utils.ErrPasswordTooLong),
+		errors.Is(err, utils.ErrPasswordTooSimple), errors.Is(err, utils.ErrPasswordHasUsername),
+		errors.Is(err, ErrSamePassword):
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, ErrWrongPassword):
+		c.JSON(http.StatusForbidden, models.Response{
+			Code: 403,
+			Msg:  err.Error(),
+		})
+	case errors.Is(err, ErrSelfDisable):
+		c.JSON(http.StatusConflict, models.Response{
+			Code: 409,
+			Msg:  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "用户操作失败",
+		})
+	}
+}
+
+// loadUser 按路径参数 id 读取用户，失败时已写入响应
+func loadUser(c *gin.Context) (*models.User, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的用户ID",
+		})
+		return nil, false
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrUserNotFound
+		}
+		respondUserError(c, err)
+		return nil, false
+	}
+	return &user, true
+}
+
+// revokeUserAccess 使用户已签发的令牌和已建立的会话全部失效，API Key 不受影响
+func revokeUserAccess(userID uint) error {
+	if err := auth.RevokeUser(userID, time.Now()); err != nil {
+		return err
+	}
+	return middleware.InvalidateUserSessions(userID)
+}
+
+// @Summary 查询用户列表
+// @Description 按用户名、角色、状态查询用户，支持分页（需 users:manage 权限）
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Param q query string false "按用户名模糊查询"
+// @Param role query string false "按角色名筛选"
+// @Param status query string false "状态: active 正常，disabled 已禁用"
+// @Param service query bool false "true 只看服务账号，false 只看普通账号"
+// @Param page query int false "页码，从 1 开始"
+// @Param page_size query int false "每页条数，默认 20，最大 100"
+// @Param sort query string false "排序字段: username/created_at，默认按 ID 倒序"
+// @Param order query string false "排序方向: asc/desc，用户名默认升序，其余默认降序"
+// @Param cursor query string false "游标，传入上一页的 next_cursor，排序参数须与上一页一致"
+// @Success 200 {object} models.Response{data=models.PageResult{items=[]models.User}} "查询成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/users [get]
+func GetUsers(c *gin.Context) {
+	query := config.DB.Model(&models.User{})
+	if q := c.Query("q"); q != "" {
+		query = query.Where("username LIKE ?", "%"+q+"%")
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch c.Query("status") {
+	case "active":
+		query = query.Where("disabled_at IS NULL")
+	case "disabled":
+		query = query.Where("disabled_at IS NOT NULL")
+	case "":
+	default:
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "无效的状态",
+		})
+		return
+	}
+	if service := c.Query("service"); service != "" {
+		v, err := strconv.ParseBool(service)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				Code: 400,
+				Msg:  "参数设定错误",
+			})
+			return
+		}
+		query = query.Where("service = ?", v)
+	}
+
+	result, err := paginate(c, query, userSorts, func(u *models.User) uint { return u.ID })
+	if err != nil {
+		respondPageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: result,
+	})
+}
+
+// @Summary 查询用户详情
+// @Description 查询用户信息，附带在借记录和未结清费用（需 users:manage 权限）
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "用户ID"
+// @Success 200 {object} models.Response{data=models.UserProfile} "查询成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "用户不存在"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/admin/users/{id} [get]
+func GetUser(c *gin.Context) {
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	profile := models.UserProfile{User: *user, ActiveLoans: []models.BorrowRecord{}}
+	err := config.DB.Preload("Book", withArchived).Preload("Copy").
+		Where("user_id = ? AND status IN ?", user.ID, activeBorrowStatuses).
+		Order("due_date ASC").
+		Find(&profile.ActiveLoans).Error
+	if err == nil {
+		profile.Balance, err = jobs.OutstandingBalance(config.DB, user.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+	fillRecordCovers(profile.ActiveLoans)
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: profile,
+	})
+}
+
+// @Summary 禁用用户
+// @Description 禁用账号：不能再登录，已建立的会话和已签发的令牌立即失效，API Key 也无法使用；不能禁用自己（需 users:manage 权限）
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "用户ID"
+// @Success 200 {object} models.Response{data=models.User} "禁用成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "用户不存在"
+// @Failure 409 {object} models.Response "不能禁用自己"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/users/{id}/disable [post]
+func DisableUser(c *gin.Context) {
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+	if user.ID == c.GetUint("user_id") {
+		respondUserError(c, ErrSelfDisable)
+		return
+	}
+
+	// 重复禁用保留最初的禁用时间，但仍再次吊销，便于上次吊销失败后重试
+	if user.DisabledAt == nil {
+		now := time.Now()
+		if err := config.DB.Model(user).Update("disabled_at", now).Error; err != nil {
+			respondUserError(c, err)
+			return
+		}
+		user.DisabledAt = &now
+	}
+	if err := revokeUserAccess(user.ID); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "用户已禁用",
+		Data: user,
+	})
+}
+
+// @Summary 启用用户
+// @Description 恢复被禁用的账号，用户需重新登录（需 users:manage 权限）
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "用户ID"
+// @Success 200 {object} models.Response{data=models.User} "启用成功"
+// @Failure 400 {object} models.Response "参数错误"
+// @Failure 404 {object} models.Response "用户不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/users/{id}/enable [post]
+func EnableUser(c *gin.Context) {
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+
+	if user.DisabledAt != nil {
+		if err := config.DB.Model(user).Update("disabled_at", nil).Error; err != nil {
+			respondUserError(c, err)
+			return
+		}
+		user.DisabledAt = nil
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "用户已启用",
+		Data: user,
+	})
+}
+
+// @Summary 重置用户密码
+// @Description 为用户设置新密码，须符合密码策略；该用户已建立的会话和已签发的令牌立即失效（需 users:manage 权限）
+// @Tags users
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "用户ID"
+// @Param request body models.ResetPasswordRequest true "新密码"
+// @Success 200 {object} models.Response "重置成功"
+// @Failure 400 {object} models.Response "参数错误或密码不符合策略"
+// @Failure 404 {object} models.Response "用户不存在"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/admin/users/{id}/password [put]
+func ResetUserPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	user, ok := loadUser(c)
+	if !ok {
+		return
+	}
+	if err := utils.CheckPasswordPolicy(req.Password, user.Username); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	hashed, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "密码加密错误",
+		})
+		return
+	}
+	if err := config.DB.Model(user).Update("password", hashed).Error; err != nil {
+		respondUserError(c, err)
+		return
+	}
+	if err := revokeUserAccess(user.ID); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "密码已重置",
+	})
+}
+
+// @Summary 查询当前用户
+// @Description 返回当前登录用户的信息，附带在借、逾期和累计借阅数量以及未结清费用
+// @Tags users
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} models.Response{data=models.MyProfile} "查询成功"
+// @Failure 404 {object} models.Response "用户不存在"
+// @Failure 500 {object} models.Response "数据库查询失败"
+// @Router /api/me [get]
+func GetMe(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrUserNotFound
+		}
+		respondUserError(c, err)
+		return
+	}
+
+	profile := models.MyProfile{
+		User:               user,
+		MaxActiveLoans:     config.GetPolicy(user.Role).MaxActiveLoans,
+		FineBlockThreshold: config.FineBlockThreshold(),
+	}
+	records := config.DB.Model(&models.BorrowRecord{}).Where("user_id = ?", user.ID)
+	err := records.Session(&gorm.Session{}).Where("status IN ?", activeBorrowStatuses).Count(&profile.ActiveLoans).Error
+	if err == nil {
+		// 扫描任务可能还未标记，直接按应还时间判断
+		err = records.Session(&gorm.Session{}).
+			Where("status IN ? AND (status = ? OR due_date < ?)", activeBorrowStatuses, models.BorrowStatusOverdue, time.Now()).
+			Count(&profile.OverdueLoans).Error
+	}
+	if err == nil {
+		err = records.Session(&gorm.Session{}).Count(&profile.TotalLoans).Error
+	}
+	if err == nil {
+		profile.Balance, err = jobs.OutstandingBalance(config.DB, user.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "数据库查询失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "查询成功",
+		Data: profile,
+	})
+}
+
+// @Summary 修改密码
+// @Description 校验原密码后设置新密码，新密码须符合密码策略；该用户其他会话和令牌立即失效，当前会话保持登录，令牌认证时返回新的令牌；不能使用 API Key 调用
+// @Tags users
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.ChangePasswordRequest true "原密码和新密码"
+// @Success 200 {object} models.Response{data=models.TokenPair} "修改成功，令牌认证时 data 为新的令牌，会话认证时为空"
+// @Failure 400 {object} models.Response "参数错误或密码不符合策略"
+// @Failure 403 {object} models.Response "原密码错误"
+// @Failure 500 {object} models.Response "服务器错误"
+// @Router /api/me/password [put]
+func ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Code: 400,
+			Msg:  "参数设定错误",
+		})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrUserNotFound
+		}
+		respondUserError(c, err)
+		return
+	}
+	if err := utils.ComparePassword(user.Password, req.OldPassword); err != nil {
+		respondUserError(c, ErrWrongPassword)
+		return
+	}
+	if err := utils.CheckPasswordPolicy(req.NewPassword, user.Username); err != nil {
+		respondUserError(c, err)
+		return
+	}
+	if utils.ComparePassword(user.Password, req.NewPassword) == nil {
+		respondUserError(c, ErrSamePassword)
+		return
+	}
+
+	hashed, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "密码加密错误",
+		})
+		return
+	}
+	if err := config.DB.Model(&user).Update("password", hashed).Error; err != nil {
+		respondUserError(c, err)
+		return
+	}
+	if err := revokeUserAccess(user.ID); err != nil {
+		respondUserError(c, err)
+		return
+	}
+
+	// 吊销包括当前会话和令牌在内的全部登录状态后，为当前调用方重新建立登录状态
+	// 吊销时间精确到秒，此时签发的令牌和记录的登录时间不受影响
+	var pair *models.TokenPair
+	if _, ok := c.Get("token_claims"); ok {
+		pair, err = auth.Tokens.Issue(user.ID, user.Role, time.Now())
+		if err != nil {
+			respondTokenError(c, err)
+			return
+		}
+	} else {
+		session := sessions.Default(c)
+		session.Set("login_at", time.Now().Unix())
+		if err := session.Save(); err != nil {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "鉴权组件错误",
+			})
+			return
+		}
+		if err := middleware.TrackSession(session, user.ID); err != nil {
+			log.Printf("记录用户 %d 的会话失败: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Code: 200,
+		Msg:  "密码修改成功",
+		Data: pair,
+	})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUserChangesRevokeAccess(t *testing.T) {
+	db := setupTestDB(t)
+	if err := auth.SeedRoles(db); err != nil {
+		t.Fatalf("初始化角色权限失败: %v", err)
+	}
+	auth.InvalidatePermissions()
+	t.Cleanup(auth.InvalidatePermissions)
+
+	oldRevocations := auth.Revocations
+	auth.Revocations = auth.NewMemoryRevocations()
+	t.Cleanup(func() { auth.Revocations = oldRevocations })
+
+	issuer, err := auth.NewIssuer([]auth.Key{{ID: "k1", Secret: []byte("secret")}}, time.Hour, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := createUser(t, db, "operator", auth.RoleAdmin)
+
+	tests := []struct {
+		name    string
+		user    string
+		method  string
+		route   string
+		path    string
+		body    string
+		handler gin.HandlerFunc
+	}{
+		{name: "禁用用户", user: "disabled", method: http.MethodPost, route: "/users/:id/disable", path: "/users/%d/disable", handler: DisableUser},
+		{name: "重置密码", user: "reset", method: http.MethodPut, route: "/users/:id/password", path: "/users/%d/password", body: `{"password":"Spring2024!"}`, handler: ResetUserPassword},
+		{name: "修改角色", user: "promoted", method: http.MethodPut, route: "/users/:id/role", path: "/users/%d/role", body: `{"role":"librarian"}`, handler: SetUserRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createUser(t, db, tt.user, auth.RoleUser)
+			loginAt := time.Now().Add(-time.Minute)
+			pair, err := issuer.Issue(user.ID, user.Role, loginAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked, _ := auth.SessionRevoked(user.ID, loginAt); revoked {
+				t.Fatal("操作前会话已失效")
+			}
+
+			w := serveJSON(tt.method, tt.route, fmt.Sprintf(tt.path, user.ID), tt.body, tt.handler,
+				map[string]interface{}{"user_id": admin.ID, "role": admin.Role})
+			if w.Code != http.StatusOK {
+				t.Fatalf("操作返回 %d: %s", w.Code, w.Body.String())
+			}
+
+			if revoked, err := auth.SessionRevoked(user.ID, loginAt); err != nil || !revoked {
+				t.Errorf("操作前建立的会话未失效: %t, %v", revoked, err)
+			}
+			if _, err := issuer.Parse(pair.AccessToken, auth.TokenTypeAccess); !errors.Is(err, auth.ErrTokenRevoked) {
+				t.Errorf("操作前签发的访问令牌返回 %v，期望 ErrTokenRevoked", err)
+			}
+			if _, err := issuer.Parse(pair.RefreshToken, auth.TokenTypeRefresh); !errors.Is(err, auth.ErrTokenRevoked) {
+				t.Errorf("操作前签发的刷新令牌返回 %v，期望 ErrTokenRevoked", err)
+			}
+
+			// 操作之后重新登录不受影响
+			if revoked, _ := auth.SessionRevoked(user.ID, time.Now().Add(time.Second)); revoked {
+				t.Error("操作之后建立的会话被判定为失效")
+			}
+			// 其他用户不受影响
+			if revoked, _ := auth.SessionRevoked(admin.ID, loginAt); revoked {
+				t.Error("操作人的会话被吊销")
+			}
+		})
+	}
+
+	// 被拒绝的操作不吊销
+	w := serveJSON(http.MethodPost, "/users/:id/disable", fmt.Sprintf("/users/%d/disable", admin.ID), "", DisableUser,
+		map[string]interface{}{"user_id": admin.ID, "role": admin.Role})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("禁用自己返回 %d，期望 409", w.Code)
+	}
+	if revoked, _ := auth.SessionRevoked(admin.ID, time.Now().Add(-time.Minute)); revoked {
+		t.Error("禁用自己失败后会话被吊销")
+	}
+
+	var got models.User
+	db.First(&got, admin.ID)
+	if got.DisabledAt != nil {
+		t.Error("管理员禁用了自己")
+	}
+}
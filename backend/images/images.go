@@ -0,0 +1,242 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("仅支持 JPEG、PNG、WebP 格式的图片")
+	ErrTooLarge          = errors.New("图片文件过大")
+	ErrDimensions        = errors.New("图片尺寸超出限制")
+	ErrCorrupt           = errors.New("图片已损坏，无法解码")
+)
+
+// IsInvalid 判断错误是否由图片本身不合格引起，而不是存储故障
+func IsInvalid(err error) bool {
+	return errors.Is(err, ErrUnsupportedFormat) || errors.Is(err, ErrTooLarge) ||
+		errors.Is(err, ErrDimensions) || errors.Is(err, ErrCorrupt)
+}
+
+// 识别出的图片格式
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// Limits 上传图片的限制，0 表示不限制
+type Limits struct {
+	MaxBytes  int64 // 文件大小上限
+	MaxWidth  int   // 宽度上限（像素）
+	MaxHeight int   // 高度上限（像素）
+}
+
+// CoverLimits 封面上传限制，启动时按配置替换
+var CoverLimits = Limits{MaxBytes: 10 << 20, MaxWidth: 6000, MaxHeight: 6000}
+
+// Variant 一种尺寸规格，图片等比缩放到长边不超过 MaxSide，原图更小时不放大
+type Variant struct {
+	Name    string
+	MaxSide int
+}
+
+// 封面的三种尺寸，full 为保存的主文件，即 Book.CoverPath
+const (
+	VariantThumb  = "thumb"
+	VariantMedium = "medium"
+	VariantFull   = "full"
+)
+
+var Variants = []Variant{
+	{Name: VariantThumb, MaxSide: 200},
+	{Name: VariantMedium, MaxSide: 600},
+	{Name: VariantFull, MaxSide: 1600},
+}
+
+// Encoded 一种规格的编码结果
+type Encoded struct {
+	Variant     string
+	Data        []byte
+	Width       int
+	Height      int
+	Ext         string
+	ContentType string
+}
+
+// Sniff 根据文件头判断格式，不信任文件名和客户端声明的类型
+func Sniff(head []byte) string {
+	switch {
+	case len(head) >= 3 && head[0] == 0xFF && head[1] == 0xD8 && head[2] == 0xFF:
+		return FormatJPEG
+	case len(head) >= 8 && bytes.Equal(head[:8], []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return ""
+}
+
+// Process 校验并重新编码图片，生成 Variants 中的全部规格
+// 重新编码会丢弃 EXIF 等元数据（JPEG 的方向信息先应用到像素上）；PNG 保持 PNG 以保留透明度，其余输出 JPEG
+func Process(r io.Reader, limits Limits) ([]Encoded, error) {
+	data, err := readLimited(r, limits.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	format := Sniff(data)
+	if format == "" {
+		return nil, ErrUnsupportedFormat
+	}
+
+	// 解码前先读取尺寸，避免超大尺寸的图片耗尽内存
+	cfg, err := decodeConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if (limits.MaxWidth > 0 && cfg.Width > limits.MaxWidth) || (limits.MaxHeight > 0 && cfg.Height > limits.MaxHeight) {
+		return nil, fmt.Errorf("%w: %dx%d，最大 %dx%d", ErrDimensions, cfg.Width, cfg.Height, limits.MaxWidth, limits.MaxHeight)
+	}
+
+	img, err := decode(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if format == FormatJPEG {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	outFormat := FormatJPEG
+	if format == FormatPNG {
+		outFormat = FormatPNG
+	}
+
+	encoded := make([]Encoded, 0, len(Variants))
+	for _, v := range Variants {
+		scaled := fit(img, v.MaxSide)
+		out, err := encode(scaled, outFormat)
+		if err != nil {
+			return nil, err
+		}
+		b := scaled.Bounds()
+		e := Encoded{Variant: v.Name, Data: out, Width: b.Dx(), Height: b.Dy(), Ext: ".jpg", ContentType: "image/jpeg"}
+		if outFormat == FormatPNG {
+			e.Ext, e.ContentType = ".png", "image/png"
+		}
+		encoded = append(encoded, e)
+	}
+	return encoded, nil
+}
+
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%w，最大 %d KB", ErrTooLarge, max>>10)
+	}
+	return data, nil
+}
+
+func decodeConfig(data []byte, format string) (image.Config, error) {
+	switch format {
+	case FormatJPEG:
+		return jpeg.DecodeConfig(bytes.NewReader(data))
+	case FormatPNG:
+		return png.DecodeConfig(bytes.NewReader(data))
+	default:
+		return webp.DecodeConfig(bytes.NewReader(data))
+	}
+}
+
+func decode(data []byte, format string) (image.Image, error) {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Decode(bytes.NewReader(data))
+	case FormatPNG:
+		return png.Decode(bytes.NewReader(data))
+	default:
+		return webp.Decode(bytes.NewReader(data))
+	}
+}
+
+// fit 等比缩小到长边不超过 maxSide
+func fit(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxSide <= 0 || (w <= maxSide && h <= maxSide) {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == FormatPNG {
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	// JPEG 不支持透明度，透明区域以白色填充（WebP 可能带透明通道）
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// VariantKey 由主文件的 key 推出指定规格的 key，如 uploads/cover_x_full.jpg -> uploads/cover_x_thumb.jpg
+// 不是按规格命名的文件（旧封面、默认封面）没有其他规格，返回 false
+func VariantKey(key, variant string) (string, bool) {
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	if !strings.HasSuffix(base, "_"+VariantFull) {
+		return "", false
+	}
+	return strings.TrimSuffix(base, VariantFull) + variant + ext, true
+}
+
+// VariantKeys 返回主文件对应的全部规格文件 key（含主文件本身）
+func VariantKeys(key string) []string {
+	keys := []string{key}
+	for _, v := range Variants {
+		if v.Name == VariantFull {
+			continue
+		}
+		if k, ok := VariantKey(key, v.Name); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
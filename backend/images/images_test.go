@@ -0,0 +1,168 @@
+package images
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "JPEG", head: []byte{0xFF, 0xD8, 0xFF, 0xE0}, want: FormatJPEG},
+		{name: "PNG", head: []byte("\x89PNG\r\n\x1a\n\x00\x00"), want: FormatPNG},
+		{name: "WebP", head: []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), want: FormatWebP},
+		{name: "GIF", head: []byte("GIF89a"), want: ""},
+		{name: "RIFF 但不是 WebP", head: []byte("RIFF\x24\x00\x00\x00WAVEfmt "), want: ""},
+		{name: "截断的 JPEG", head: []byte{0xFF, 0xD8}, want: ""},
+		{name: "截断的 PNG", head: []byte("\x89PNG"), want: ""},
+		{name: "空", head: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff(tt.head); got != tt.want {
+				t.Errorf("Sniff 返回 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		variant string
+		want    string
+		wantOK  bool
+	}{
+		{key: "uploads/cover_1_ab_full.jpg", variant: VariantThumb, want: "uploads/cover_1_ab_thumb.jpg", wantOK: true},
+		{key: "uploads/cover_1_ab_full.png", variant: VariantMedium, want: "uploads/cover_1_ab_medium.png", wantOK: true},
+		{key: "uploads/cover_1_ab_full", variant: VariantThumb, want: "uploads/cover_1_ab_thumb", wantOK: true},
+		{key: "uploads/default.png", variant: VariantThumb},
+		{key: "uploads/cover_1_ab.jpg", variant: VariantThumb},
+		{key: "uploads/cover_1_fullsize.jpg", variant: VariantThumb},
+	}
+	for _, tt := range tests {
+		got, ok := VariantKey(tt.key, tt.variant)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("VariantKey(%q, %q) 返回 %q, %v，期望 %q, %v", tt.key, tt.variant, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestVariantKeys(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{key: "uploads/cover_1_ab_full.jpg", want: []string{"uploads/cover_1_ab_full.jpg", "uploads/cover_1_ab_thumb.jpg", "uploads/cover_1_ab_medium.jpg"}},
+		{key: "uploads/default.png", want: []string{"uploads/default.png"}},
+	}
+	for _, tt := range tests {
+		if got := VariantKeys(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VariantKeys(%q) 返回 %q，期望 %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+// exifJPEG 拼出只含 EXIF 段的 JPEG 文件头，order 为 TIFF 字节序，orientation 为 0 时不写方向标记
+func exifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+
+	entry := make([]byte, 12)
+	tag := uint16(exifOrientationTag)
+	if orientation == 0 {
+		tag = 0x010F // 相机厂商
+	}
+	order.PutUint16(entry[0:], tag)
+	order.PutUint16(entry[2:], 3) // SHORT
+	order.PutUint32(entry[4:], 1)
+	order.PutUint16(entry[8:], orientation)
+	count := make([]byte, 2)
+	order.PutUint16(count, 1)
+	tiff = append(tiff, count...)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0) // 下一个 IFD 偏移
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8}
+	// 方向之前先放一个 APP0 段，验证会跳过其他段
+	data = append(data, 0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00)
+	data = append(data, 0xFF, 0xE1)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	truncated := exifJPEG(binary.BigEndian, 6)
+	truncated = truncated[:len(truncated)-20]
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "小端序", data: exifJPEG(binary.LittleEndian, 6), want: 6},
+		{name: "大端序", data: exifJPEG(binary.BigEndian, 3), want: 3},
+		{name: "正常方向", data: exifJPEG(binary.BigEndian, 1), want: 1},
+		{name: "超出范围的值", data: exifJPEG(binary.BigEndian, 9), want: 1},
+		{name: "没有方向标记", data: exifJPEG(binary.LittleEndian, 0), want: 1},
+		{name: "段长度超出文件", data: truncated, want: 1},
+		{name: "没有 EXIF", data: []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, want: 1},
+		{name: "不是 JPEG", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation 返回 %d，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 2x1 的图片，左红右蓝
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		want        [][]color.RGBA // 按行列出结果像素
+	}{
+		{orientation: 1, want: [][]color.RGBA{{red, blue}}},
+		{orientation: 2, want: [][]color.RGBA{{blue, red}}},
+		{orientation: 3, want: [][]color.RGBA{{blue, red}}},
+		{orientation: 6, want: [][]color.RGBA{{red}, {blue}}},
+		{orientation: 8, want: [][]color.RGBA{{blue}, {red}}},
+	}
+	for _, tt := range tests {
+		got := applyOrientation(src, tt.orientation)
+		b := got.Bounds()
+		if b.Dy() != len(tt.want) || b.Dx() != len(tt.want[0]) {
+			t.Errorf("方向 %d 结果尺寸为 %dx%d，期望 %dx%d", tt.orientation, b.Dx(), b.Dy(), len(tt.want[0]), len(tt.want))
+			continue
+		}
+		for y, row := range tt.want {
+			for x, want := range row {
+				if c := color.RGBAModel.Convert(got.At(b.Min.X+x, b.Min.Y+y)); c != want {
+					t.Errorf("方向 %d 的像素 (%d,%d) 为 %v，期望 %v", tt.orientation, x, y, c, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+// 计入总库存的单册状态
+var circulatingCopyStatuses = []string{
+	models.CopyStatusAvailable,
+	models.CopyStatusOnHold,
+	models.CopyStatusOnLoan,
+}
+
+// SyncBookStock 根据单册状态重新计算图书的可借库存与总库存
+func SyncBookStock(tx *gorm.DB, book *models.Book) error {
+	var stock, total int64
+	if err := tx.Model(&models.Copy{}).
+		Where("book_id = ? AND status = ?", book.ID, models.CopyStatusAvailable).
+		Count(&stock).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Copy{}).
+		Where("book_id = ? AND status IN ?", book.ID, circulatingCopyStatuses).
+		Count(&total).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(book).Updates(map[string]interface{}{
+		"stock":       int(stock),
+		"total_stock": int(total),
+	}).Error
+}
+
+// NewBarcode 为图书生成下一个单册条码，格式为 BK<图书ID>-<序号>
+func NewBarcode(tx *gorm.DB, bookID uint) (string, error) {
+	var count int64
+	if err := tx.Model(&models.Copy{}).Where("book_id = ?", bookID).Count(&count).Error; err != nil {
+		return "", err
+	}
+
+	for seq := count + 1; ; seq++ {
+		barcode := fmt.Sprintf("BK%06d-%03d", bookID, seq)
+		var exists int64
+		if err := tx.Model(&models.Copy{}).Where("barcode = ?", barcode).Count(&exists).Error; err != nil {
+			return "", err
+		}
+		if exists == 0 {
+			return barcode, nil
+		}
+	}
+}
+
+// CreateCopies 为图书批量新增 n 册在架单册
+func CreateCopies(tx *gorm.DB, bookID uint, n int, location string) ([]models.Copy, error) {
+	copies := make([]models.Copy, 0, n)
+	for i := 0; i < n; i++ {
+		barcode, err := NewBarcode(tx, bookID)
+		if err != nil {
+			return nil, err
+		}
+		item := models.Copy{
+			BookID:    bookID,
+			Barcode:   barcode,
+			Location:  location,
+			Condition: models.CopyConditionGood,
+			Status:    models.CopyStatusAvailable,
+		}
+		if err := tx.Omit("Book").Create(&item).Error; err != nil {
+			return nil, err
+		}
+		copies = append(copies, item)
+	}
+	return copies, nil
+}
+
+// MigrateCopies 为引入单册之前的图书按原有库存数生成单册，并关联在借记录与保留中的预约
+// 库存为 0 且没有在借记录的图书不会生成单册，不参与迁移，否则每次启动都会重新扫描
+func MigrateCopies(db *gorm.DB) error {
+	var books []models.Book
+	if err := db.Where("NOT EXISTS (SELECT 1 FROM copies WHERE copies.book_id = books.id)").
+		Where(db.Where("total_stock > 0").
+			Or("EXISTS (SELECT 1 FROM borrow_records WHERE borrow_records.book_id = books.id AND borrow_records.copy_id IS NULL AND borrow_records.status IN ?)",
+				[]string{models.BorrowStatusBorrowed, models.BorrowStatusOverdue})).
+		Find(&books).Error; err != nil {
+		return err
+	}
+
+	for i := range books {
+		book := &books[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var loans []models.BorrowRecord
+			if err := tx.Where("book_id = ? AND copy_id IS NULL AND status IN ?", book.ID,
+				[]string{models.BorrowStatusBorrowed, models.BorrowStatusOverdue}).
+				Find(&loans).Error; err != nil {
+				return err
+			}
+
+			n := book.TotalStock
+			if n < len(loans) {
+				n = len(loans)
+			}
+			copies, err := CreateCopies(tx, book.ID, n, "")
+			if err != nil {
+				return err
+			}
+
+			for j, loan := range loans {
+				if err := tx.Model(&copies[j]).Update("status", models.CopyStatusOnLoan).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&loan).Update("copy_id", copies[j].ID).Error; err != nil {
+					return err
+				}
+			}
+
+			// 已保留的预约占用剩余的在架单册，不够时退回排队
+			var ready []models.Reservation
+			if err := tx.Where("book_id = ? AND status = ? AND copy_id IS NULL", book.ID, models.ReservationStatusReady).
+				Order("id ASC").Find(&ready).Error; err != nil {
+				return err
+			}
+			next := len(loans)
+			for _, r := range ready {
+				if next < len(copies) {
+					if err := tx.Model(&copies[next]).Update("status", models.CopyStatusOnHold).Error; err != nil {
+						return err
+					}
+					if err := tx.Model(&r).Update("copy_id", copies[next].ID).Error; err != nil {
+						return err
+					}
+					next++
+					continue
+				}
+				if err := tx.Model(&r).Updates(map[string]interface{}{
+					"status":     models.ReservationStatusWaiting,
+					"ready_at":   nil,
+					"expires_at": nil,
+				}).Error; err != nil {
+					return err
+				}
+			}
+
+			return SyncBookStock(tx, book)
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("图书 %d 已生成单册数据", book.ID)
+	}
+
+	return nil
+}
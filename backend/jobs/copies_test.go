@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestMigrateCopies(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "reader", Password: "x", Role: "user"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	books := []models.Book{
+		{Title: "有库存", Author: "甲", TotalStock: 2, Stock: 2},
+		{Title: "无库存", Author: "乙"},
+		{Title: "无库存但在借", Author: "丙"},
+	}
+	if err := db.Create(&books).Error; err != nil {
+		t.Fatal(err)
+	}
+	loan := models.BorrowRecord{UserID: user.ID, BookID: books[2].ID, Status: models.BorrowStatusBorrowed}
+	if err := db.Create(&loan).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		book models.Book
+		want int64
+	}{
+		{name: "按库存生成单册", book: books[0], want: 2},
+		{name: "无库存不生成", book: books[1], want: 0},
+		{name: "在借记录生成单册", book: books[2], want: 1},
+	}
+	for round := 1; round <= 2; round++ {
+		if err := MigrateCopies(db); err != nil {
+			t.Fatalf("第 %d 次迁移失败: %v", round, err)
+		}
+		for _, tt := range tests {
+			var n int64
+			db.Model(&models.Copy{}).Where("book_id = ?", tt.book.ID).Count(&n)
+			if n != tt.want {
+				t.Errorf("第 %d 次迁移后%s: %d 册，期望 %d", round, tt.name, n, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"path"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/images"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
+	"gorm.io/gorm"
+)
+
+// coverDir 封面所在目录，清理只扫描该目录
+const coverDir = "uploads"
+
+// CoverGCReport 一次封面清理的结果
+type CoverGCReport struct {
+	Scanned int              `json:"scanned"` // 扫描的文件数
+	Recent  int              `json:"recent"`  // 未被引用但仍在宽限期内、暂不处理的文件数
+	Orphans []storage.Object `json:"orphans"` // 未被引用且超过宽限期的文件
+	Deleted int              `json:"deleted"` // 实际删除的文件数，试运行时为 0
+	DryRun  bool             `json:"dry_run"`
+}
+
+// referencedCovers 收集所有图书（含已归档）引用的封面及其各尺寸规格，默认封面始终视为被引用
+func referencedCovers(db *gorm.DB) (map[string]bool, error) {
+	var paths []string
+	if err := db.Unscoped().Model(&models.Book{}).
+		Where("cover_path <> ''").
+		Distinct().Pluck("cover_path", &paths).Error; err != nil {
+		return nil, err
+	}
+
+	refs := map[string]bool{models.DefaultCoverPath: true}
+	for _, p := range paths {
+		for _, key := range images.VariantKeys(path.Clean(p)) {
+			refs[key] = true
+		}
+	}
+	return refs, nil
+}
+
+// CollectOrphanCovers 找出封面目录中没有任何图书引用的文件，dryRun 为 false 时删除
+// 修改时间在 grace 之内的文件不处理：上传后尚未提交事务的封面还没有被图书引用
+func CollectOrphanCovers(ctx context.Context, db *gorm.DB, store storage.Storage, now time.Time, grace time.Duration, dryRun bool) (*CoverGCReport, error) {
+	// 先列文件再查引用，列出之后才提交的图书引用也能被看到
+	objects, err := store.List(ctx, coverDir)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := referencedCovers(db)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CoverGCReport{Scanned: len(objects), Orphans: []storage.Object{}, DryRun: dryRun}
+	cutoff := now.Add(-grace)
+	for _, obj := range objects {
+		if refs[obj.Key] {
+			continue
+		}
+		if obj.ModTime.After(cutoff) {
+			report.Recent++
+			continue
+		}
+		report.Orphans = append(report.Orphans, obj)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	for _, obj := range report.Orphans {
+		if obj.Key == models.DefaultCoverPath {
+			continue
+		}
+		if err := store.Delete(ctx, obj.Key); err != nil {
+			log.Printf("封面清理: 删除 %s 失败: %v", obj.Key, err)
+			continue
+		}
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// StartCoverGC 启动封面清理任务，按 interval 定期删除孤立的封面文件，ctx 取消后退出
+func StartCoverGC(ctx context.Context, db *gorm.DB, store storage.Storage, interval, grace time.Duration, dryRun bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("封面清理任务已停止")
+				return
+			case <-ticker.C:
+			}
+
+			report, err := CollectOrphanCovers(ctx, db, store, time.Now(), grace, dryRun)
+			if err != nil {
+				log.Printf("封面清理失败: %v", err)
+				continue
+			}
+			if dryRun && len(report.Orphans) > 0 {
+				log.Printf("封面清理（试运行）: 发现 %d 个孤立文件", len(report.Orphans))
+			} else if report.Deleted > 0 {
+				log.Printf("封面清理: 删除 %d 个孤立文件", report.Deleted)
+			}
+		}
+	}()
+}
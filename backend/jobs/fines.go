@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccrueOverdueFee 按借阅者角色的策略计算逾期罚金，并写入该借阅记录对应的罚金台账
+// 已减免的罚金不再变动；罚金增长后已结清的条目重新变为未结清
+// 调用方需已锁定借阅记录，罚金台账在此加锁，与缴费、减免互斥
+func AccrueOverdueFee(tx *gorm.DB, record *models.BorrowRecord, role string, end time.Time) error {
+	if record.DueDate == nil {
+		return nil
+	}
+
+	amount := config.GetPolicy(role).OverdueFine(*record.DueDate, end)
+
+	var fee models.Fee
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("borrow_record_id = ? AND type = ?", record.ID, models.FeeTypeOverdue).
+		First(&fee).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if amount <= 0 {
+			return nil
+		}
+		fee = models.Fee{
+			UserID:         record.UserID,
+			BorrowRecordID: record.ID,
+			Type:           models.FeeTypeOverdue,
+			Amount:         amount,
+			Status:         models.FeeStatusUnpaid,
+		}
+		return tx.Create(&fee).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if fee.Status == models.FeeStatusWaived || fee.Amount == amount {
+		return nil
+	}
+
+	status := models.FeeStatusUnpaid
+	if fee.PaidAmount >= amount {
+		status = models.FeeStatusPaid
+	}
+	return tx.Model(&fee).Updates(map[string]interface{}{
+		"amount": amount,
+		"status": status,
+	}).Error
+}
+
+// AccrueFines 为所有逾期未还的借阅记录更新罚金，返回更新的记录数
+// 每条记录单独开事务并锁定后重新读取，扫描期间已归还的记录跳过；单条失败只记录日志，不影响其余记录
+func AccrueFines(db *gorm.DB, now time.Time) (int, error) {
+	var ids []uint
+	if err := db.Model(&models.BorrowRecord{}).
+		Where("status = ?", models.BorrowStatusOverdue).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	accrued := 0
+	for _, id := range ids {
+		updated := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var record models.BorrowRecord
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND status = ?", id, models.BorrowStatusOverdue).
+				First(&record).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+
+			var borrower models.User
+			if err := tx.Select("id", "role").First(&borrower, record.UserID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err := AccrueOverdueFee(tx, &record, borrower.Role, now); err != nil {
+				return err
+			}
+			updated = true
+			return nil
+		})
+		if err != nil {
+			log.Printf("罚金计算: 借阅记录 %d 处理失败: %v", id, err)
+			continue
+		}
+		if updated {
+			accrued++
+		}
+	}
+
+	return accrued, nil
+}
+
+// OutstandingBalance 统计用户未结清的费用总额（分）
+func OutstandingBalance(tx *gorm.DB, userID uint) (int64, error) {
+	var balance int64
+	err := tx.Model(&models.Fee{}).
+		Where("user_id = ? AND status = ?", userID, models.FeeStatusUnpaid).
+		Select("COALESCE(SUM(amount - paid_amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+func TestAccrueFines(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "reader", Password: "x", Role: "user"}
+	book := models.Book{Title: "围城", Author: "钱锺书"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&book).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 普通读者每天 50 分，宽限 1 天：逾期 5 天罚 200 分
+	now := time.Now()
+	due := now.Add(-5 * 24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		status     string
+		fee        *models.Fee // 已有的罚金台账
+		wantAmount int64
+		wantStatus string
+	}{
+		{name: "新增罚金", status: models.BorrowStatusOverdue, wantAmount: 200, wantStatus: models.FeeStatusUnpaid},
+		{name: "罚金增长后重新变为未结清", status: models.BorrowStatusOverdue,
+			fee: &models.Fee{Amount: 100, PaidAmount: 100, Status: models.FeeStatusPaid}, wantAmount: 200, wantStatus: models.FeeStatusUnpaid},
+		{name: "已减免的不变", status: models.BorrowStatusOverdue,
+			fee: &models.Fee{Amount: 100, Status: models.FeeStatusWaived}, wantAmount: 100, wantStatus: models.FeeStatusWaived},
+		{name: "已归还的不再计算", status: models.BorrowStatusReturned,
+			fee: &models.Fee{Amount: 50, Status: models.FeeStatusUnpaid}, wantAmount: 50, wantStatus: models.FeeStatusUnpaid},
+	}
+
+	records := make([]models.BorrowRecord, len(tests))
+	for i, tt := range tests {
+		records[i] = models.BorrowRecord{UserID: user.ID, BookID: book.ID, BorrowDate: due.AddDate(0, 0, -30), DueDate: &due, Status: tt.status}
+		if err := db.Create(&records[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if tt.fee != nil {
+			fee := *tt.fee
+			fee.UserID, fee.BorrowRecordID, fee.Type = user.ID, records[i].ID, models.FeeTypeOverdue
+			if err := db.Create(&fee).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	n, err := AccrueFines(db, now)
+	if err != nil {
+		t.Fatalf("罚金计算失败: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("处理了 %d 条记录，期望 3 条", n)
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fee models.Fee
+			if err := db.Where("borrow_record_id = ? AND type = ?", records[i].ID, models.FeeTypeOverdue).First(&fee).Error; err != nil {
+				t.Fatalf("查询罚金失败: %v", err)
+			}
+			if fee.Amount != tt.wantAmount || fee.Status != tt.wantStatus {
+				t.Errorf("罚金为 %d/%s，期望 %d/%s", fee.Amount, fee.Status, tt.wantAmount, tt.wantStatus)
+			}
+		})
+	}
+}
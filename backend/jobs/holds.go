@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PromoteHolds 把在架单册按先来后到保留给排队中的预约，并同步图书库存，调用方需已锁定图书行
+func PromoteHolds(tx *gorm.DB, book *models.Book, now time.Time, pickupWindow time.Duration) error {
+	var copies []models.Copy
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("book_id = ? AND status = ?", book.ID, models.CopyStatusAvailable).
+		Order("id ASC").
+		Find(&copies).Error; err != nil {
+		return err
+	}
+
+	if len(copies) > 0 {
+		var waiting []models.Reservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("book_id = ? AND status = ?", book.ID, models.ReservationStatusWaiting).
+			Order("id ASC").
+			Limit(len(copies)).
+			Find(&waiting).Error; err != nil {
+			return err
+		}
+
+		expiresAt := now.Add(pickupWindow)
+		for i, r := range waiting {
+			if err := tx.Model(&copies[i]).Update("status", models.CopyStatusOnHold).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&r).Updates(map[string]interface{}{
+				"status":     models.ReservationStatusReady,
+				"copy_id":    copies[i].ID,
+				"ready_at":   now,
+				"expires_at": expiresAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return SyncBookStock(tx, book)
+}
+
+// ReleaseHold 释放预约保留的单册，使其回到在架状态
+func ReleaseHold(tx *gorm.DB, reservation *models.Reservation) error {
+	if reservation.CopyID == nil {
+		return nil
+	}
+	return tx.Model(&models.Copy{}).
+		Where("id = ? AND status = ?", *reservation.CopyID, models.CopyStatusOnHold).
+		Update("status", models.CopyStatusAvailable).Error
+}
+
+// ExpireHolds 释放超过取书期限的预约，并把单册顺延给队列中的下一位
+func ExpireHolds(db *gorm.DB, now time.Time, pickupWindow time.Duration) (int64, error) {
+	var bookIDs []uint
+	if err := db.Model(&models.Reservation{}).
+		Where("status = ? AND expires_at < ?", models.ReservationStatusReady, now).
+		Distinct("book_id").
+		Pluck("book_id", &bookIDs).Error; err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for _, bookID := range bookIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var book models.Book
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, bookID).Error; err != nil {
+				return err
+			}
+
+			var reservations []models.Reservation
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("book_id = ? AND status = ? AND expires_at < ?", bookID, models.ReservationStatusReady, now).
+				Find(&reservations).Error; err != nil {
+				return err
+			}
+
+			for i := range reservations {
+				if err := tx.Model(&reservations[i]).Update("status", models.ReservationStatusExpired).Error; err != nil {
+					return err
+				}
+				if err := ReleaseHold(tx, &reservations[i]); err != nil {
+					return err
+				}
+			}
+
+			if err := PromoteHolds(tx, &book, now, pickupWindow); err != nil {
+				return err
+			}
+			expired += int64(len(reservations))
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+// MarkOverdue 将已过应还时间但仍在借的记录标记为逾期，返回受影响的记录数
+func MarkOverdue(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&models.BorrowRecord{}).
+		Where("status = ? AND due_date IS NOT NULL AND due_date < ?", models.BorrowStatusBorrowed, now).
+		Update("status", models.BorrowStatusOverdue)
+	return result.RowsAffected, result.Error
+}
+
+// StartSweeper 启动后台扫描任务：标记逾期借阅、累计罚金、释放过期预约，ctx 取消后退出
+func StartSweeper(ctx context.Context, db *gorm.DB, interval, pickupWindow time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			now := time.Now()
+			if n, err := MarkOverdue(db, now); err != nil {
+				log.Printf("逾期扫描失败: %v", err)
+			} else if n > 0 {
+				log.Printf("逾期扫描: 标记 %d 条借阅记录为逾期", n)
+			}
+
+			if _, err := AccrueFines(db, now); err != nil {
+				log.Printf("罚金计算失败: %v", err)
+			}
+
+			if n, err := ExpireHolds(db, now, pickupWindow); err != nil {
+				log.Printf("预约过期扫描失败: %v", err)
+			} else if n > 0 {
+				log.Printf("预约过期扫描: %d 条预约已过期", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("后台扫描任务已停止")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -12,20 +12,34 @@
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+// @description 不使用 Cookie 会话时填写 Bearer <access_token>，令牌由 /api/auth/token 签发
+// @securityDefinitions.apikey APIKeyHeader
+// @in header
+// @name X-API-Key
+// @description 集成调用使用的 API Key，由 /api/api-keys 创建，按权限范围限制可访问的接口
 package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/catalog"
 	"github.com/Dailiduzhou/library_manage_sys/config"
+	"github.com/Dailiduzhou/library_manage_sys/images"
+	"github.com/Dailiduzhou/library_manage_sys/jobs"
 	"github.com/Dailiduzhou/library_manage_sys/middleware"
 	"github.com/Dailiduzhou/library_manage_sys/routes"
+	"github.com/Dailiduzhou/library_manage_sys/search"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
+	"github.com/Dailiduzhou/library_manage_sys/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
@@ -35,8 +49,73 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	config.ConnectDB()
 	config.InitAdmin(config.DB)
+	if err := auth.SeedRoles(config.DB); err != nil {
+		log.Fatal("初始化角色权限失败:", err)
+	}
+
+	storageConfig := config.StorageConfig()
+	coverStorage, err := storage.Open(storageConfig)
+	if err != nil {
+		log.Fatal("封面存储初始化失败:", err)
+	}
+	storage.Covers = coverStorage
+	images.CoverLimits = config.CoverLimits()
+	seedCtx, cancelSeed := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := utils.EnsureDefaultCover(seedCtx, coverStorage); err != nil {
+		log.Printf("写入默认封面失败，未上传封面的图书将无法显示封面: %v", err)
+	}
+	cancelSeed()
+
+	if err := jobs.MigrateCopies(config.DB); err != nil {
+		log.Fatal("单册数据迁移失败:", err)
+	}
+	if err := catalog.BackfillPinyin(config.DB); err != nil {
+		log.Fatal("补全图书拼音失败:", err)
+	}
+
+	searchIndex, err := search.Open(config.DB, config.SearchBackend())
+	if err != nil {
+		log.Fatal("搜索索引初始化失败:", err)
+	}
+	search.Books = searchIndex
+	defer searchIndex.Close()
+
+	if err := config.ConnectRedis(); err != nil {
+		log.Printf("Redis 不可用，搜索联想和令牌吊销列表使用进程内实现: %v", err)
+		search.Suggestions = search.NewMemorySuggester(config.DB)
+	} else {
+		search.Suggestions = search.NewRedisSuggester(config.DB, config.Redis)
+		auth.Revocations = auth.NewRedisRevocations(config.Redis)
+		defer config.Redis.Close()
+	}
+
+	// 未配置签名密钥时不启用令牌认证，避免使用公开的默认密钥
+	tokenKeys, err := config.TokenKeys()
+	if errors.Is(err, config.ErrNoTokenKeys) {
+		log.Println("警告: 未配置 JWT_KEYS，令牌认证已禁用，Bearer 请求和令牌接口将被拒绝；开发环境可设置 GIN_MODE=debug 使用开发密钥")
+	} else {
+		if err != nil {
+			log.Fatal("令牌签名密钥配置错误:", err)
+		}
+		tokens, err := auth.NewIssuer(tokenKeys, config.AccessTokenTTL(), config.RefreshTokenTTL())
+		if err != nil {
+			log.Fatal("令牌签发器初始化失败:", err)
+		}
+		auth.Tokens = tokens
+	}
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobs.StartSweeper(jobCtx, config.DB, config.SweepInterval(), config.HoldPickupWindow())
+	if config.CoverGCEnabled() {
+		jobs.StartCoverGC(jobCtx, config.DB, storage.Covers, config.CoverGCInterval(), config.CoverGCGrace(), config.CoverGCDryRun())
+	}
 
 	r := gin.Default()
 
@@ -53,6 +132,7 @@ func main() {
 			"Authorization",
 			"Accept",
 			"X-Requested-With",
+			"X-API-Key",
 		},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -61,7 +141,14 @@ func main() {
 	}
 
 	r.Use(cors.New(corsConfig))
-	r.Static("/uploads", "./uploads")
+	// 本地存储时由本服务提供封面访问，路由与 STORAGE_LOCAL_BASE_URL 生成的地址一致；对象存储时封面地址直接指向存储服务
+	if local, ok := coverStorage.(*storage.Local); ok {
+		if route := local.URLPath("uploads"); route != "" {
+			r.Static(route, filepath.Join(storageConfig.LocalRoot, "uploads"))
+		} else {
+			log.Printf("STORAGE_LOCAL_BASE_URL 无法解析，未注册封面静态路由")
+		}
+	}
 
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
@@ -92,6 +179,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("正在关闭服务器...")
+	stopJobs()
 
 	// 设置 5 秒超时，处理未完成的请求
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -1,18 +1,36 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Dailiduzhou/library_manage_sys/auth"
+	"github.com/Dailiduzhou/library_manage_sys/config"
 	"github.com/Dailiduzhou/library_manage_sys/models"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
+	redigo "github.com/gomodule/redigo/redis"
 )
 
+const (
+	sessionMaxAge = 86400 * 7
+
+	// 与 redistore 默认的键前缀一致
+	sessionKeyPrefix     = "session_"
+	userSessionKeyPrefix = "user_sessions:"
+)
+
+// sessionPool 会话存储使用的 Redis 连接池，使用 Cookie 存储时为空
+var sessionPool *redigo.Pool
+
 func InitSession(r *gin.Engine) error {
 	useRedis := os.Getenv("USE_REDIS")
 	if useRedis == "" {
@@ -37,7 +55,7 @@ func InitSession(r *gin.Engine) error {
 
 	store.Options(sessions.Options{
 		Path:     "/",
-		MaxAge:   86400 * 7,
+		MaxAge:   sessionMaxAge,
 		HttpOnly: true,
 		Secure:   false,
 		SameSite: 0,
@@ -76,19 +94,71 @@ func initRedisStore(sessionSecret []byte) (sessions.Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("连接 Redis 失败: %w", err)
 	}
+	if rs, err := redis.GetRedisStore(store); err == nil {
+		sessionPool = rs.Pool
+	}
 
 	log.Println("Redis 存储初始化成功")
 	return store, nil
 }
 
+// TrackSession 记录用户的会话 ID，供 InvalidateUserSessions 删除；需在 session.Save 之后调用
+func TrackSession(session sessions.Session, userID uint) error {
+	if sessionPool == nil || session.ID() == "" {
+		return nil
+	}
+	conn := sessionPool.Get()
+	defer conn.Close()
+
+	key := userSessionKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+	if _, err := conn.Do("SADD", key, session.ID()); err != nil {
+		return err
+	}
+	_, err := conn.Do("EXPIRE", key, sessionMaxAge)
+	return err
+}
+
+// InvalidateUserSessions 从 Redis 中删除用户的全部会话
+// Cookie 存储无法在服务端删除会话，依靠 auth.RevokeUser 记录的时间点在 AuthRequired 中拒绝
+func InvalidateUserSessions(userID uint) error {
+	if sessionPool == nil {
+		return nil
+	}
+	conn := sessionPool.Get()
+	defer conn.Close()
+
+	key := userSessionKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+	ids, err := redigo.Strings(conn.Do("SMEMBERS", key))
+	if err != nil {
+		return err
+	}
+	args := redigo.Args{}.Add(key)
+	for _, id := range ids {
+		args = args.Add(sessionKeyPrefix + id)
+	}
+	_, err = conn.Do("DEL", args...)
+	return err
+}
+
 func initCookieStore(sessionSecret []byte) sessions.Store {
 	log.Println("使用 Cookie 存储（开发环境）")
 	store := cookie.NewStore(sessionSecret)
 	return store
 }
 
+// AuthRequired 要求已登录：依次尝试 X-API-Key 请求头、Authorization: Bearer 请求头和会话
+// 三种方式都在上下文中写入 user_id 和 role；令牌认证时另写入 token_claims，供登出时吊销；API Key 认证时另写入 api_key，供 RequireScope 检查
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if raw := c.GetHeader("X-API-Key"); raw != "" {
+			apiKeyAuth(c, raw)
+			return
+		}
+		if header := c.GetHeader("Authorization"); header != "" {
+			bearerAuth(c, header)
+			return
+		}
+
 		session := sessions.Default(c)
 		userID := session.Get("user_id")
 
@@ -122,28 +192,147 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// 登录时间早于用户被吊销的时间点（禁用、重置密码、修改角色）时会话失效
+		loginAt, _ := session.Get("login_at").(int64)
+		revoked, err := auth.SessionRevoked(finalID, time.Unix(loginAt, 0))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "处理用户身份错误",
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			session.Clear()
+			_ = session.Save()
+			c.JSON(http.StatusUnauthorized, models.Response{
+				Code: 401,
+				Msg:  "会话已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", finalID)
+		if role, ok := session.Get("role").(string); ok {
+			c.Set("role", role)
+		}
 
 		c.Next()
 	}
 }
 
-func AdminRequired() gin.HandlerFunc {
+func bearerAuth(c *gin.Context, header string) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		c.JSON(http.StatusUnauthorized, models.Response{
+			Code: 401,
+			Msg:  "认证方式不支持",
+		})
+		c.Abort()
+		return
+	}
+
+	claims, err := auth.Tokens.Parse(strings.TrimSpace(token), auth.TokenTypeAccess)
+	if err == nil {
+		var userID uint
+		if userID, err = claims.UserID(); err == nil {
+			c.Set("user_id", userID)
+			c.Set("role", claims.Role)
+			c.Set("token_claims", claims)
+			c.Next()
+			return
+		}
+	}
+
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired), errors.Is(err, auth.ErrTokenRevoked), errors.Is(err, auth.ErrInvalidToken),
+		errors.Is(err, auth.ErrTokensOff):
+		c.JSON(http.StatusUnauthorized, models.Response{
+			Code: 401,
+			Msg:  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Code: 500,
+			Msg:  "令牌校验失败",
+		})
+	}
+	c.Abort()
+}
+
+func apiKeyAuth(c *gin.Context, raw string) {
+	key, err := auth.AuthenticateAPIKey(config.DB, raw, time.Now())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) || errors.Is(err, auth.ErrAPIKeyExpired) || errors.Is(err, auth.ErrAPIKeyRevoked) ||
+			errors.Is(err, auth.ErrAccountDisabled) {
+			c.JSON(http.StatusUnauthorized, models.Response{
+				Code: 401,
+				Msg:  err.Error(),
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				Code: 500,
+				Msg:  "API Key 校验失败",
+			})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", key.UserID)
+	c.Set("role", key.User.Role)
+	c.Set("api_key", key)
+	c.Next()
+}
+
+// RequireScope 使用 API Key 认证时要求具有指定权限范围，会话和令牌认证不受限制；需在 AuthRequired 之后使用
+func RequireScope(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session := sessions.Default(c)
-		role := session.Get("role")
+		if v, ok := c.Get("api_key"); ok && !auth.HasScope(v.(*models.APIKey), scope) {
+			c.JSON(http.StatusForbidden, models.Response{
+				Code: 403,
+				Msg:  "API Key 缺少权限范围: " + scope,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
 
-		if roleStr, ok := role.(string); !ok {
+// DenyAPIKey 禁止使用 API Key 访问，用于 API Key 自身的管理等只允许本人操作的接口
+func DenyAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key"); ok {
+			c.JSON(http.StatusForbidden, models.Response{
+				Code: 403,
+				Msg:  "该接口不能使用 API Key 访问",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 要求当前角色拥有指定权限，权限按数据库中的角色配置检查；需在 AuthRequired 之后使用
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, err := auth.RoleHasPermission(config.DB, c.GetString("role"), perm)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.Response{
 				Code: 500,
 				Msg:  "处理用户身份错误",
 			})
 			c.Abort()
 			return
-		} else if roleStr != "admin" {
-			c.JSON(http.StatusUnauthorized, models.Response{
-				Code: 401,
-				Msg:  "权限不足",
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, models.Response{
+				Code: 403,
+				Msg:  "权限不足，需要 " + perm + " 权限",
 			})
 			c.Abort()
 			return
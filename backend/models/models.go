@@ -12,6 +12,50 @@ const (
 	DefaultSummary   = "暂无简介"
 )
 
+// 借阅记录状态
+const (
+	BorrowStatusBorrowed = "borrowed"
+	BorrowStatusOverdue  = "overdue"
+	BorrowStatusReturned = "returned"
+)
+
+// 预约状态
+const (
+	ReservationStatusWaiting   = "waiting"   // 排队中
+	ReservationStatusReady     = "ready"     // 已为读者保留，等待取书
+	ReservationStatusFulfilled = "fulfilled" // 已借出
+	ReservationStatusCancelled = "cancelled" // 读者取消
+	ReservationStatusExpired   = "expired"   // 超过取书期限
+)
+
+// 单册流通状态
+const (
+	CopyStatusAvailable = "available" // 在架可借
+	CopyStatusOnHold    = "on_hold"   // 为预约读者保留
+	CopyStatusOnLoan    = "on_loan"   // 已借出
+	CopyStatusDamaged   = "damaged"   // 损坏待修，不可借
+	CopyStatusLost      = "lost"      // 遗失
+	CopyStatusWithdrawn = "withdrawn" // 剔旧注销
+)
+
+// 单册品相
+const (
+	CopyConditionGood    = "good"
+	CopyConditionWorn    = "worn"
+	CopyConditionDamaged = "damaged"
+)
+
+// 费用类型与状态
+const (
+	FeeTypeOverdue = "overdue" // 逾期罚金
+	FeeTypeLost    = "lost"    // 遗失赔偿
+	FeeTypeDamaged = "damaged" // 损坏赔偿
+
+	FeeStatusUnpaid = "unpaid"
+	FeeStatusPaid   = "paid"
+	FeeStatusWaived = "waived"
+)
+
 // @Description 通用响应结构
 // @param code int "状态码"
 // @param msg string "消息内容"
@@ -22,32 +66,203 @@ type Response struct {
 	Data interface{} `json:"data"`
 }
 
+// @Description 搜索联想结果，field 为 title 时 text 是书名，为 author 时 text 是作者名，book_id 为对应的一本图书
+type BookSuggestion struct {
+	BookID uint   `json:"book_id"`
+	Field  string `json:"field"`
+	Text   string `json:"text"`
+}
+
+// @Description 分页结果，next_cursor 为空表示没有下一页
+type PageResult struct {
+	Items      interface{} `json:"items"`
+	Total      int64       `json:"total"`
+	Page       int         `json:"page,omitempty"` // 游标分页时为空
+	PageSize   int         `json:"page_size"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Facets     interface{} `json:"facets,omitempty"` // 仅图书列表返回
+}
+
+// @Description 分面统计中的一项，分类分面带分类号
+type FacetCount struct {
+	ID    uint   `json:"id"`
+	Code  string `json:"code,omitempty"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// @Description 图书列表的分面统计：categories 为当前所选分类（未选时为顶级分类）的下级分类，计数包含其所有子分类中的图书
+type BookFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Tags       []FacetCount `json:"tags"`
+}
+
 // @Summary 图书模型
-// @Description 图书详细信息
+// @Description 图书详细信息，deleted_at 非空表示已归档：不在列表和检索中出现，借阅历史中仍可见，可恢复
 type Book struct {
 	ID        uint   `gorm:"primaryKey" json:"id"`
 	Title     string `json:"title"`
 	Author    string `json:"author"`
 	Summary   string `json:"summary"`
-	CoverPath string `json:"cover_path"`
+	CoverPath string `json:"cover_path"`         // 封面在存储中的 key（大图）
+	CoverURL  string `gorm:"-" json:"cover_url"` // 封面访问地址，由接口层按当前存储后端生成
+
+	// 缩略图和中图地址，旧封面和默认封面没有其他规格时与 cover_url 相同
+	CoverThumbURL  string `gorm:"-" json:"cover_thumb_url"`
+	CoverMediumURL string `gorm:"-" json:"cover_medium_url"`
 
+	// ISBN13 为规范化后的 ISBN-13（ISBN-10 入库时自动转换），未知时为空
+	ISBN13 *string `gorm:"size:13;uniqueIndex" json:"isbn13"`
+	ISBN10 string  `gorm:"size:10" json:"isbn10"`
+
+	// 书名与作者的全拼和首字母，写入时生成，用于拼音检索
+	TitlePinyin    string `gorm:"size:255;index" json:"-"`
+	TitleInitials  string `gorm:"size:255;index" json:"-"`
+	AuthorPinyin   string `gorm:"size:255;index" json:"-"`
+	AuthorInitials string `gorm:"size:255;index" json:"-"`
+
+	// Stock 与 TotalStock 由单册状态汇总得出，不直接修改
 	InitialStock int `json:"initial_stock" gorm:"default:0" binding:"gte=0"`
-	Stock        int `json:"stock" gorm:"default:0" binding:"gte=0"`
-	TotalStock   int `json:"total_stock" gorm:"defualt:0" binding:"gte=0"`
+	Stock        int `json:"stock" gorm:"default:0" binding:"gte=0"`       // 在架可借册数
+	TotalStock   int `json:"total_stock" gorm:"defualt:0" binding:"gte=0"` // 在馆流通册数（不含损坏、遗失、注销）
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	// PurgedAt 非空表示图书已彻底删除，仅为借阅历史保留书名和作者，不能恢复
+	PurgedAt *time.Time `gorm:"index" json:"purged_at,omitempty"`
 
+	Categories []Category `gorm:"many2many:book_categories" json:"categories,omitempty"`
+	Tags       []Tag      `gorm:"many2many:book_tags" json:"tags,omitempty"`
+}
+
+// @Description 图书详情，在图书信息之外附带实时可借情况；recent_loans 仅管理员可见
+type BookDetail struct {
+	Book
+	OnLoan      int64      `json:"on_loan"`       // 已借出册数
+	OnHold      int64      `json:"on_hold"`       // 为预约读者保留的册数
+	NextDueDate *time.Time `json:"next_due_date"` // 在借单册中最早的应还时间，无在借时为空
+	HoldQueue   int64      `json:"hold_queue"`    // 排队中的预约数
+
+	RecentLoans []BorrowRecord `json:"recent_loans,omitempty"`
+}
+
+// @Description 图书分类，按中图分类法等分类体系组织为树
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	Code     string `gorm:"size:32;uniqueIndex;not null" json:"code"` // 分类号，如 I247
+	Name     string `gorm:"size:100;not null" json:"name"`
+	ParentID *uint  `gorm:"index" json:"parent_id"` // 顶级分类为空
+	// Path 为从根到本分类的 ID 路径，如 "/1/5/12/"，用于查询整棵子树
+	Path string `gorm:"size:255;index" json:"path"`
+
+	Children []Category `gorm:"-" json:"children,omitempty"`
 }
 
-// @Summary 用户信息
-// @Description 用户结构体
-type User struct {
+// @Description 图书标签，自由填写
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Name string `gorm:"size:50;uniqueIndex;not null" json:"name"`
+}
+
+// @Description 图书单册（实体书），以条码区分
+type Copy struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	Password  string    `gorm:"not null" json:"-"`
-	Role      string    `gorm:"default:'user'" json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	BookID    uint   `gorm:"index;not null" json:"book_id"`
+	Barcode   string `gorm:"size:64;uniqueIndex;not null" json:"barcode"`
+	Location  string `json:"location"`                                // 馆藏位置/索书号
+	Condition string `gorm:"default:'good'" json:"condition"`         // good/worn/damaged
+	Status    string `gorm:"index;default:'available'" json:"status"` // available/on_hold/on_loan/damaged/lost/withdrawn
+
+	Book *Book `json:"book,omitempty" swaggerignore:"true"`
+}
+
+// @Summary 用户信息
+// @Description 用户结构体
+type User struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Username   string     `gorm:"unique;not null" json:"username"`
+	Password   string     `gorm:"not null" json:"-"`
+	Role       string     `gorm:"default:'user'" json:"role"`
+	Service    bool       `gorm:"default:false" json:"service"` // 服务账号，只能通过 API Key 访问，不能登录
+	DisabledAt *time.Time `gorm:"index" json:"disabled_at"`     // 禁用时间，非空表示账号已禁用，不能登录也不能使用令牌和 API Key
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// @Description 用户详情，附带在借记录和未结清费用
+type UserProfile struct {
+	User
+	ActiveLoans []BorrowRecord `json:"active_loans"` // 在借和逾期的借阅记录，含图书和单册信息
+	Balance     int64          `json:"balance"`      // 未结清费用（分）
+}
+
+// @Description 当前用户信息，附带借阅统计和费用余额
+type MyProfile struct {
+	User
+	ActiveLoans        int64 `json:"active_loans"`         // 在借册数，含逾期
+	OverdueLoans       int64 `json:"overdue_loans"`        // 逾期未还册数
+	MaxActiveLoans     int   `json:"max_active_loans"`     // 按角色流通策略可同时在借的册数
+	TotalLoans         int64 `json:"total_loans"`          // 累计借阅次数
+	Balance            int64 `json:"balance"`              // 未结清费用（分）
+	FineBlockThreshold int64 `json:"fine_block_threshold"` // 未结清费用超过该值时不能借书（分）
+}
+
+// @Description 角色，User.Role 保存角色名；内置角色不能删除，admin 始终拥有全部权限
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"size:50;uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	Builtin     bool         `gorm:"default:false" json:"builtin"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// @Description 权限，code 形如 books:delete，由程序定义，启动时写入数据库
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Code        string `gorm:"size:50;uniqueIndex;not null" json:"code"`
+	Description string `gorm:"size:255" json:"description"`
+}
+
+// @Description API Key，供自助借还机、报表脚本等集成调用；明文只在创建时返回一次，库中只保存哈希
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	User       *User      `json:"user,omitempty"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16" json:"prefix"` // 明文的前几位，便于辨认
+	KeyHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"` // 为空表示长期有效
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// @Description 新创建的 API Key，key 为明文，只返回这一次
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// @Description 访问令牌和刷新令牌，访问令牌通过 Authorization: Bearer <access_token> 请求头使用
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`         // 访问令牌有效期（秒）
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // 刷新令牌有效期（秒）
 }
 
 // @Description 借阅记录
@@ -57,9 +272,12 @@ type User struct {
 // @property deleted_at string "删除时间 (RFC3339) 可为空"
 // @property user_id uint "用户ID"
 // @property book_id uint "图书ID"
+// @property copy_id uint "借出的单册ID"
 // @property borrow_date string "借出时间 (RFC3339)"
+// @property due_date string "应还时间 (RFC3339)"
 // @property return_date string "归还时间 (RFC3339) 未归还时为空"
-// @property status string "状态: borrowed/returned"
+// @property status string "状态: borrowed/overdue/returned"
+// @property renew_count int "已续借次数"
 type BorrowRecord struct {
 	// 显式定义 gorm.Model 的字段
 	ID        uint           `gorm:"primaryKey" json:"id"`
@@ -69,11 +287,84 @@ type BorrowRecord struct {
 
 	UserID     uint       `json:"user_id"`
 	BookID     uint       `json:"book_id"`
+	CopyID     *uint      `gorm:"index" json:"copy_id"`
 	BorrowDate time.Time  `json:"borrow_date"`
+	DueDate    *time.Time `gorm:"index" json:"due_date"`
 	ReturnDate *time.Time `json:"return_date"`
-	Status     string     `json:"status"` // borrowed/returned
+	Status     string     `gorm:"index" json:"status"` // borrowed/overdue/returned
+	RenewCount int        `gorm:"default:0" json:"renew_count"`
 
 	// 关联关系
 	User *User `json:"user,omitempty" swaggerignore:"true"`
 	Book *Book `json:"book,omitempty" swaggerignore:"true"`
+	Copy *Copy `json:"copy,omitempty" swaggerignore:"true"`
+}
+
+// @Description 续借记录，每次续借生成一条
+type Renewal struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	BorrowRecordID uint      `gorm:"index" json:"borrow_record_id"`
+	UserID         uint      `gorm:"index" json:"user_id"`
+	OldDueDate     time.Time `json:"old_due_date"`
+	NewDueDate     time.Time `json:"new_due_date"`
+}
+
+// @Description 图书预约，同一本书按创建顺序排队
+type Reservation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"index" json:"user_id"`
+	BookID    uint       `gorm:"index" json:"book_id"`
+	Status    string     `gorm:"index" json:"status"` // waiting/ready/fulfilled/cancelled/expired
+	CopyID    *uint      `json:"copy_id"`             // 为其保留的单册
+	ReadyAt   *time.Time `json:"ready_at"`            // 开始保留的时间
+	ExpiresAt *time.Time `json:"expires_at"`          // 保留截止时间
+
+	// 排队位置，仅 waiting 状态有效，从 1 开始
+	Position int `gorm:"-" json:"position"`
+
+	User *User `json:"user,omitempty" swaggerignore:"true"`
+	Book *Book `json:"book,omitempty" swaggerignore:"true"`
+}
+
+// @Description 费用台账，金额单位均为分
+type Fee struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID         uint   `gorm:"index" json:"user_id"`
+	BorrowRecordID uint   `gorm:"index" json:"borrow_record_id"`
+	Type           string `json:"type"` // overdue/lost/damaged
+	Amount         int64  `json:"amount"`
+	PaidAmount     int64  `gorm:"default:0" json:"paid_amount"`
+	Status         string `gorm:"index" json:"status"` // unpaid/paid/waived
+	Note           string `json:"note"`
+	OperatorID     *uint  `json:"operator_id"` // 最后处理的管理员，系统计费时为空
+
+	Payments     []FeePayment  `json:"payments,omitempty" swaggerignore:"true"`
+	BorrowRecord *BorrowRecord `json:"borrow_record,omitempty" swaggerignore:"true"`
+}
+
+// Outstanding 尚未结清的金额
+func (f Fee) Outstanding() int64 {
+	if f.Status != FeeStatusUnpaid {
+		return 0
+	}
+	return f.Amount - f.PaidAmount
+}
+
+// @Description 缴费记录
+type FeePayment struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	FeeID      uint   `gorm:"index" json:"fee_id"`
+	Amount     int64  `json:"amount"`
+	OperatorID uint   `json:"operator_id"`
+	Note       string `json:"note"`
 }
@@ -1,6 +1,9 @@
 package models
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+	"time"
+)
 
 // @Summary 用户注册请求
 // @Description 用户注册所需参数
@@ -16,26 +19,81 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// @Description 刷新或吊销令牌时提交的刷新令牌
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // @Summary 创建图书请求
 // @Description 创建新图书所需参数
 type CreateBookRequest struct {
 	Title        string                `form:"title" binding:"required"`
 	Author       string                `form:"author" binding:"required"`
 	Summary      string                `form:"summary" binding:"omitempty"`
+	ISBN         string                `form:"isbn" binding:"omitempty"`
 	Cover        *multipart.FileHeader `form:"cover" binding:"omitempty"`
 	InitialStock int                   `form:"initial_stock" binding:"gte=0"`
 }
 
 // @Summary 更新图书请求
-// @Description 更新图书信息参数
+// @Description 更新图书信息参数，库存由单册状态决定，不在此修改
 type UpdateBookRequest struct {
-	ID         uint                  `form:"id"`
-	Title      string                `form:"title" binding:"omitempty"`
-	Author     string                `form:"author" binding:"omitempty"`
-	Summary    string                `form:"summary" binding:"omitempty"`
-	Cover      *multipart.FileHeader `form:"cover" binding:"omitempty"`
-	Stock      int                   `form:"stock" binding:"omitempty"`
-	TotalStock int                   `form:"total_stock" binding:"omitempty"`
+	ID      uint                  `form:"id"`
+	Title   string                `form:"title" binding:"omitempty"`
+	Author  string                `form:"author" binding:"omitempty"`
+	Summary string                `form:"summary" binding:"omitempty"`
+	ISBN    string                `form:"isbn" binding:"omitempty"`
+	Cover   *multipart.FileHeader `form:"cover" binding:"omitempty"`
+}
+
+// @Summary 借还书请求
+// @Description 扫码时传单册条码，否则传图书ID，二者至少一个
+type CirculationRequest struct {
+	ID      uint   `json:"id" binding:"required_without=Barcode"`
+	Barcode string `json:"barcode" binding:"required_without=ID"`
+}
+
+// @Summary 新增单册请求
+// @Description 为图书新增单册，未指定条码时按 count 批量生成
+type CreateCopyRequest struct {
+	Barcode   string `json:"barcode" binding:"omitempty,max=64"`
+	Location  string `json:"location"`
+	Condition string `json:"condition" binding:"omitempty,oneof=good worn damaged"`
+	Count     int    `json:"count" binding:"omitempty,gte=1,lte=100"`
+}
+
+// @Summary 更新单册请求
+// @Description 修改单册位置、品相或状态，已借出或保留中的单册不能直接改状态
+type UpdateCopyRequest struct {
+	Location  string `json:"location"`
+	Condition string `json:"condition" binding:"omitempty,oneof=good worn damaged"`
+	Status    string `json:"status" binding:"omitempty,oneof=available damaged lost withdrawn"`
+}
+
+// @Summary 分类请求
+// @Description 新增或修改分类，parent_id 为空表示顶级分类
+type CategoryRequest struct {
+	Code     string `json:"code" binding:"required,max=32"`
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// @Summary 标签请求
+// @Description 新增或重命名标签
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+// @Summary 设置图书分类请求
+// @Description 用给定分类替换图书当前的全部分类，传空数组表示清空
+type SetBookCategoriesRequest struct {
+	CategoryIDs []uint `json:"category_ids"`
+}
+
+// @Summary 设置图书标签请求
+// @Description 用给定标签替换图书当前的全部标签，不存在的标签自动创建，传空数组表示清空
+type SetBookTagsRequest struct {
+	Tags []string `json:"tags" binding:"dive,max=50"`
 }
 
 // @Summary 通用图书查询请求
@@ -43,3 +101,71 @@ type UpdateBookRequest struct {
 type FindBookRequest struct {
 	ID uint `json:"id" binding:"required"`
 }
+
+// @Summary 登记费用请求
+// @Description 为借阅记录登记遗失或损坏赔偿，金额单位为分
+type CreateFeeRequest struct {
+	BorrowRecordID uint   `json:"borrow_record_id" binding:"required"`
+	Type           string `json:"type" binding:"required,oneof=lost damaged"`
+	Amount         int64  `json:"amount" binding:"required,gt=0"`
+	Note           string `json:"note"`
+}
+
+// @Summary 缴费请求
+// @Description 记录一次缴费，金额单位为分
+type PayFeeRequest struct {
+	Amount int64  `json:"amount" binding:"required,gt=0"`
+	Note   string `json:"note"`
+}
+
+// @Summary 减免费用请求
+// @Description 减免费用时的备注
+type WaiveFeeRequest struct {
+	Note string `json:"note"`
+}
+
+// @Summary 分页查询参数
+// @Description page/page_size 为页码分页，cursor 为游标分页（传入上一页返回的 next_cursor，忽略 page）
+type PageQuery struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Sort     string `form:"sort"`
+	Order    string `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor   string `form:"cursor"`
+}
+
+// @Description 创建 API Key，scopes 取值见 GET /api/api-keys/scopes
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"` // 过期时间 (RFC3339)，为空表示长期有效
+}
+
+// @Description 创建服务账号，服务账号只能通过 API Key 访问
+type CreateServiceAccountRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role"` // 角色名，默认为 user
+}
+
+// @Description 创建或修改角色，permissions 为权限 code 列表，修改时 name 不可变更
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"omitempty,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+// @Description 为用户分配角色
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// @Description 管理员重置用户密码，新密码须符合密码策略：至少 8 位，同时包含字母和数字，不能包含用户名
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// @Description 修改自己的密码，新密码须符合密码策略：至少 8 位，同时包含字母和数字，不能包含用户名
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
@@ -1,40 +1,102 @@
 package routes
 
 import (
+	"github.com/Dailiduzhou/library_manage_sys/auth"
 	controller "github.com/Dailiduzhou/library_manage_sys/controllers"
 	"github.com/Dailiduzhou/library_manage_sys/middleware"
 	"github.com/gin-gonic/gin"
 )
 
+// RegisterBookRouters 注册图书与借阅相关路由，使用 API Key 访问时各路由组要求对应的权限范围
 func RegisterBookRouters(r *gin.Engine) {
 	api := r.Group("/api")
 
 	authGroup := api.Group("/")
 	authGroup.Use(middleware.AuthRequired())
 	{
-		authGroup.POST("/records/:id", controller.BorrowRecords)
-		borrows := authGroup.Group("/borrows")
+		circulation := authGroup.Group("/", middleware.RequireScope(auth.ScopeCirculate))
 		{
-			// 创建借阅记录 (借书)
-			borrows.POST("", controller.BorrowBook)
-			borrows.POST("/return", controller.ReturnBook)
+			circulation.POST("/records/:id", controller.BorrowRecords)
+			borrows := circulation.Group("/borrows")
+			{
+				// 创建借阅记录 (借书)
+				borrows.POST("", controller.BorrowBook)
+				borrows.POST("/return", controller.ReturnBook)
+				borrows.POST("/:id/renew", controller.RenewBook)
+			}
 		}
 
-		authGroup.GET("/books", controller.GetBooks)
+		reservations := authGroup.Group("/reservations", middleware.RequireScope(auth.ScopeReservations))
+		{
+			reservations.POST("", controller.PlaceReservation)
+			reservations.GET("", controller.GetMyReservations)
+			reservations.DELETE("/:id", controller.CancelReservation)
+		}
+
+		authGroup.GET("/fees", middleware.RequireScope(auth.ScopeFees), controller.GetMyFees)
+
+		catalog := authGroup.Group("/", middleware.RequireScope(auth.ScopeReadBooks))
+		{
+			catalog.GET("/books", controller.GetBooks)
+			catalog.GET("/books/search", controller.SearchBooks)
+			catalog.GET("/books/suggest", controller.SuggestBooks)
+			catalog.GET("/books/isbn/:isbn", controller.GetBookByISBN)
+			catalog.GET("/books/:id", controller.GetBook)
+			catalog.GET("/categories", controller.GetCategories)
+			catalog.GET("/tags", controller.GetTags)
+		}
 
+		// 管理接口按角色权限逐个控制，角色与权限在 /api/admin/roles 维护
 		adminGroup := authGroup.Group("/admin")
-		adminGroup.Use(middleware.AdminRequired())
 		{
-			// POST /books 创建
-			adminGroup.POST("/books", controller.CreateBook)
-			// PUT /books/:id 更新
-			adminGroup.PUT("/books/:id", controller.UpdateBook)
-			// DELETE /books/:id 删除
-			adminGroup.DELETE("/books/:id", controller.DeleteBooks)
+			adminCatalog := adminGroup.Group("/", middleware.RequireScope(auth.ScopeAdminCatalog))
+			{
+				// POST /books 创建
+				adminCatalog.POST("/books", middleware.RequirePermission(auth.PermBooksCreate), controller.CreateBook)
+				// PUT /books/:id 更新
+				adminCatalog.PUT("/books/:id", middleware.RequirePermission(auth.PermBooksUpdate), controller.UpdateBook)
+				// DELETE /books/:id 删除
+				adminCatalog.DELETE("/books/:id", middleware.RequirePermission(auth.PermBooksDelete), controller.DeleteBooks)
+				adminCatalog.POST("/books/:id/restore", middleware.RequirePermission(auth.PermBooksDelete), controller.RestoreBook)
+				adminCatalog.DELETE("/books/:id/purge", middleware.RequirePermission(auth.PermBooksDelete), controller.PurgeBook)
+				adminCatalog.POST("/books/import", middleware.RequirePermission(auth.PermBooksImport), controller.ImportBooks)
+				adminCatalog.POST("/books/import/marc", middleware.RequirePermission(auth.PermBooksImport), controller.ImportMARC)
+				adminCatalog.PUT("/books/:id/categories", middleware.RequirePermission(auth.PermBooksUpdate), controller.SetBookCategories)
+				adminCatalog.PUT("/books/:id/tags", middleware.RequirePermission(auth.PermBooksUpdate), controller.SetBookTags)
+
+				adminCatalog.POST("/categories", middleware.RequirePermission(auth.PermCategoriesManage), controller.CreateCategory)
+				adminCatalog.PUT("/categories/:id", middleware.RequirePermission(auth.PermCategoriesManage), controller.UpdateCategory)
+				adminCatalog.DELETE("/categories/:id", middleware.RequirePermission(auth.PermCategoriesManage), controller.DeleteCategory)
+				adminCatalog.POST("/tags", middleware.RequirePermission(auth.PermCategoriesManage), controller.CreateTag)
+				adminCatalog.PUT("/tags/:id", middleware.RequirePermission(auth.PermCategoriesManage), controller.UpdateTag)
+				adminCatalog.DELETE("/tags/:id", middleware.RequirePermission(auth.PermCategoriesManage), controller.DeleteTag)
+
+				adminCatalog.GET("/books/:id/copies", middleware.RequirePermission(auth.PermCopiesManage), controller.GetBookCopies)
+				adminCatalog.POST("/books/:id/copies", middleware.RequirePermission(auth.PermCopiesManage), controller.AddCopies)
+				adminCatalog.PUT("/copies/:id", middleware.RequirePermission(auth.PermCopiesManage), controller.UpdateCopy)
+				adminCatalog.GET("/copies/barcode/:barcode", middleware.RequirePermission(auth.PermCopiesManage), controller.GetCopyByBarcode)
+			}
+
+			adminCirculation := adminGroup.Group("/", middleware.RequireScope(auth.ScopeAdminCirculation))
+			{
+				adminCirculation.GET("/books/:id/reservations", middleware.RequirePermission(auth.PermRecordsRead), controller.GetBookReservations)
+
+				adminCirculation.GET("/fees", middleware.RequirePermission(auth.PermFeesManage), controller.GetFees)
+				adminCirculation.POST("/fees", middleware.RequirePermission(auth.PermFeesManage), controller.CreateFee)
+				adminCirculation.POST("/fees/:id/payments", middleware.RequirePermission(auth.PermFeesManage), controller.PayFee)
+				adminCirculation.POST("/fees/:id/waive", middleware.RequirePermission(auth.PermFeesManage), controller.WaiveFee)
+
+				adminCirculation.GET("/records", middleware.RequirePermission(auth.PermRecordsRead), controller.GetAllBorrowRecords)
+				adminCirculation.GET("/records/overdue", middleware.RequirePermission(auth.PermRecordsRead), controller.GetOverdueRecords)
 
-			adminGroup.GET("/records", controller.GetAllBorrowRecords)
+				adminCirculation.POST("/records/:id", middleware.RequirePermission(auth.PermRecordsRead), controller.BorrowRecordsByID)
+			}
 
-			adminGroup.POST("/records/:id", controller.BorrowRecordsByID)
+			export := adminGroup.Group("/export", middleware.RequireScope(auth.ScopeAdminExport))
+			{
+				export.GET("/books", middleware.RequirePermission(auth.PermExportBooks), controller.ExportBooks)
+				export.GET("/records", middleware.RequirePermission(auth.PermExportRecords), controller.ExportBorrowRecords)
+			}
 		}
 	}
 }
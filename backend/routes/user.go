@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/Dailiduzhou/library_manage_sys/auth"
 	controller "github.com/Dailiduzhou/library_manage_sys/controllers"
 	"github.com/Dailiduzhou/library_manage_sys/middleware"
 	"github.com/gin-gonic/gin"
@@ -10,16 +11,60 @@ func RegisterUserRoutes(r *gin.Engine) {
 	api := r.Group("/api")
 	{
 
-		auth := api.Group("/auth")
+		authAPI := api.Group("/auth")
 		{
-			auth.POST("/register", controller.Register)
-			auth.POST("/login", controller.Login)
+			authAPI.POST("/register", controller.Register)
+			authAPI.POST("/login", controller.Login)
+			authAPI.POST("/token", controller.IssueToken)
+			authAPI.POST("/token/refresh", controller.RefreshToken)
+			authAPI.POST("/token/revoke", controller.RevokeToken)
 		}
 
 		authGroup := api.Group("/")
 		authGroup.Use(middleware.AuthRequired())
 		{
 			authGroup.POST("/logout", controller.Logout)
+			authGroup.GET("/me", controller.GetMe)
+			authGroup.PUT("/me/password", middleware.DenyAPIKey(), controller.ChangePassword)
+
+			// API Key 只能由本人登录后管理，不能用 API Key 创建或吊销 Key
+			keys := authGroup.Group("/api-keys", middleware.DenyAPIKey())
+			{
+				keys.GET("", controller.GetMyAPIKeys)
+				keys.POST("", controller.CreateAPIKey)
+				keys.GET("/scopes", controller.GetAPIKeyScopes)
+				keys.DELETE("/:id", controller.RevokeMyAPIKey)
+			}
+
+			adminGroup := authGroup.Group("/admin", middleware.DenyAPIKey())
+			{
+				keyAdmin := adminGroup.Group("/", middleware.RequirePermission(auth.PermAPIKeysManage))
+				{
+					keyAdmin.GET("/api-keys", controller.GetAPIKeys)
+					keyAdmin.DELETE("/api-keys/:id", controller.RevokeAPIKey)
+					keyAdmin.POST("/users/:id/api-keys", controller.CreateUserAPIKey)
+				}
+
+				users := adminGroup.Group("/", middleware.RequirePermission(auth.PermUsersManage))
+				{
+					users.GET("/users", controller.GetUsers)
+					users.GET("/users/:id", controller.GetUser)
+					users.POST("/users/:id/disable", controller.DisableUser)
+					users.POST("/users/:id/enable", controller.EnableUser)
+					users.PUT("/users/:id/password", controller.ResetUserPassword)
+					users.POST("/service-accounts", controller.CreateServiceAccount)
+				}
+				adminGroup.PUT("/users/:id/role", middleware.RequirePermission(auth.PermRolesManage), controller.SetUserRole)
+
+				roles := adminGroup.Group("/", middleware.RequirePermission(auth.PermRolesManage))
+				{
+					roles.GET("/permissions", controller.GetPermissions)
+					roles.GET("/roles", controller.GetRoles)
+					roles.POST("/roles", controller.CreateRole)
+					roles.PUT("/roles/:id", controller.UpdateRole)
+					roles.DELETE("/roles/:id", controller.DeleteRole)
+				}
+			}
 		}
 	}
 }
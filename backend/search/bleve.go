@@ -0,0 +1,111 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// 各字段的相关度权重
+var fieldBoosts = map[string]float64{
+	"title":   3,
+	"author":  2,
+	"summary": 1,
+}
+
+// BleveIndex 基于 bleve 的内存倒排索引，适合测试和小规模部署，进程重启后需重建
+type BleveIndex struct {
+	index bleve.Index
+}
+
+func NewBleveIndex() (*BleveIndex, error) {
+	textField := bleve.NewTextFieldMapping()
+	textField.Analyzer = cjk.AnalyzerName
+	textField.Store = true
+	textField.IncludeTermVectors = true
+
+	bookMapping := bleve.NewDocumentMapping()
+	for field := range fieldBoosts {
+		bookMapping.AddFieldMappingsAt(field, textField)
+	}
+
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.DefaultMapping = bookMapping
+	indexMapping.DefaultAnalyzer = cjk.AnalyzerName
+
+	index, err := bleve.NewMemOnly(indexMapping)
+	if err != nil {
+		return nil, err
+	}
+	return &BleveIndex{index: index}, nil
+}
+
+func (b *BleveIndex) IndexBooks(books ...models.Book) error {
+	batch := b.index.NewBatch()
+	for _, book := range books {
+		if err := batch.Index(strconv.FormatUint(uint64(book.ID), 10), map[string]interface{}{
+			"title":   book.Title,
+			"author":  book.Author,
+			"summary": book.Summary,
+		}); err != nil {
+			return err
+		}
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *BleveIndex) DeleteBooks(ids ...uint) error {
+	batch := b.index.NewBatch()
+	for _, id := range ids {
+		batch.Delete(strconv.FormatUint(uint64(id), 10))
+	}
+	return b.index.Batch(batch)
+}
+
+func (b *BleveIndex) Search(text string, offset, limit int) (*Result, error) {
+	var queries []query.Query
+	for field, boost := range fieldBoosts {
+		q := bleve.NewMatchQuery(text)
+		q.SetField(field)
+		q.SetBoost(boost)
+		queries = append(queries, q)
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewDisjunctionQuery(queries...), limit, offset, false)
+	req.Highlight = bleve.NewHighlightWithStyle(html.Name)
+	req.Highlight.Fields = []string{"title", "author", "summary"}
+
+	res, err := b.index.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Total: int64(res.Total), Hits: []Hit{}}
+	for _, h := range res.Hits {
+		id, err := strconv.ParseUint(h.ID, 10, 32)
+		if err != nil {
+			continue
+		}
+		hit := Hit{BookID: uint(id), Score: h.Score, Highlights: map[string][]string{}}
+		// bleve 对未命中的字段也会返回原文片段，只保留含命中词的字段
+		for field, fragments := range h.Fragments {
+			for _, f := range fragments {
+				if strings.Contains(f, "<mark>") {
+					hit.Highlights[field] = fragments
+					break
+				}
+			}
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+func (b *BleveIndex) Close() error {
+	return b.index.Close()
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+)
+
+// newTestIndex 创建带三本书的 bleve 索引
+func newTestIndex(t *testing.T) *BleveIndex {
+	t.Helper()
+
+	idx, err := NewBleveIndex()
+	if err != nil {
+		t.Fatalf("创建索引失败: %v", err)
+	}
+	t.Cleanup(func() { idx.Close() })
+
+	if err := idx.IndexBooks(
+		models.Book{ID: 1, Title: "红楼梦", Author: "曹雪芹", Summary: "中国古典小说，以贾宝玉和林黛玉的爱情悲剧为主线"},
+		models.Book{ID: 2, Title: "围城", Author: "钱锺书", Summary: "讽刺小说 <b>"},
+		models.Book{ID: 3, Title: "The Go Programming Language", Author: "Alan Donovan", Summary: "红楼 mentioned"},
+	); err != nil {
+		t.Fatalf("写入索引失败: %v", err)
+	}
+	return idx
+}
+
+func TestBleveIndexSearch(t *testing.T) {
+	idx := newTestIndex(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantIDs    []uint
+		wantField  string // 第一条结果中应高亮的字段
+		wantMarked string
+	}{
+		{name: "书名权重高于简介", query: "红楼", wantIDs: []uint{1, 3}, wantField: "title", wantMarked: "<mark>红楼</mark>梦"},
+		{name: "作者", query: "曹雪芹", wantIDs: []uint{1}, wantField: "author", wantMarked: "<mark>曹雪芹</mark>"},
+		{name: "英文不区分大小写", query: "go", wantIDs: []uint{3}, wantField: "title", wantMarked: "The <mark>Go</mark> Programming Language"},
+		{name: "简介中的片段已转义", query: "讽刺", wantIDs: []uint{2}, wantField: "summary", wantMarked: "<mark>讽刺</mark>小说 &lt;b&gt;"},
+		{name: "未命中", query: "不存在"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := idx.Search(tt.query, 0, 10)
+			if err != nil {
+				t.Fatalf("检索失败: %v", err)
+			}
+			if res.Total != int64(len(tt.wantIDs)) || len(res.Hits) != len(tt.wantIDs) {
+				t.Fatalf("命中 %d 条，期望 %v", res.Total, tt.wantIDs)
+			}
+			for i, id := range tt.wantIDs {
+				if res.Hits[i].BookID != id {
+					t.Errorf("第 %d 条结果为图书 %d，期望 %d", i+1, res.Hits[i].BookID, id)
+				}
+			}
+			if len(res.Hits) == 0 {
+				return
+			}
+
+			first := res.Hits[0].Highlights
+			if got := first[tt.wantField]; len(got) == 0 || got[0] != tt.wantMarked {
+				t.Errorf("%s 高亮为 %v，期望 %q", tt.wantField, got, tt.wantMarked)
+			}
+			for field, fragments := range first {
+				if !strings.Contains(strings.Join(fragments, ""), "<mark>") {
+					t.Errorf("未命中的字段 %s 不应返回片段", field)
+				}
+			}
+		})
+	}
+}
+
+func TestBleveIndexPagingAndDelete(t *testing.T) {
+	idx := newTestIndex(t)
+
+	res, err := idx.Search("小说", 1, 1)
+	if err != nil {
+		t.Fatalf("检索失败: %v", err)
+	}
+	if res.Total != 2 || len(res.Hits) != 1 {
+		t.Errorf("分页检索返回 %d 条，总数 %d，期望 1 条，总数 2", len(res.Hits), res.Total)
+	}
+
+	if err := idx.DeleteBooks(1); err != nil {
+		t.Fatalf("删除索引失败: %v", err)
+	}
+	res, err = idx.Search("红楼", 0, 10)
+	if err != nil {
+		t.Fatalf("检索失败: %v", err)
+	}
+	if len(res.Hits) != 1 || res.Hits[0].BookID != 3 {
+		t.Errorf("删除后检索结果为 %+v，期望只有图书 3", res.Hits)
+	}
+}
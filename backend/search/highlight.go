@@ -0,0 +1,110 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// snippetRadius 片段中命中词前后保留的字符数
+const snippetRadius = 30
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// queryTerms 将查询拆分为用于高亮的词：按空白和标点切分，连续的中日韩文字再按二元切分，与 ngram 分词一致
+func queryTerms(query string) []string {
+	tokens := strings.FieldsFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+
+	var terms []string
+	for _, token := range tokens {
+		terms = append(terms, token)
+		runes := []rune(token)
+		if len(runes) <= 2 {
+			continue
+		}
+		for i := 0; i+2 <= len(runes); i++ {
+			if isCJK(runes[i]) && isCJK(runes[i+1]) {
+				terms = append(terms, string(runes[i:i+2]))
+			}
+		}
+	}
+	return terms
+}
+
+// highlight 在文本中标记命中的词，返回围绕第一个命中位置的片段，未命中时返回 nil
+func highlight(text string, terms []string) []string {
+	orig := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(orig) {
+		lower = orig
+	}
+
+	var spans [][2]int
+	for _, term := range terms {
+		t := []rune(strings.ToLower(term))
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == string(t) {
+				spans = append(spans, [2]int{i, i + len(t)})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s[0] <= last[1] {
+			if s[1] > last[1] {
+				last[1] = s[1]
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	start := merged[0][0] - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := merged[0][1] + snippetRadius*2
+	if end > len(orig) {
+		end = len(orig)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	cur := start
+	for _, s := range merged {
+		if s[0] >= end {
+			break
+		}
+		if s[1] > end {
+			s[1] = end
+		}
+		b.WriteString(html.EscapeString(string(orig[cur:s[0]])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(orig[s[0]:s[1]])))
+		b.WriteString("</mark>")
+		cur = s[1]
+	}
+	b.WriteString(html.EscapeString(string(orig[cur:end])))
+	if end < len(orig) {
+		b.WriteString("…")
+	}
+
+	return []string{b.String()}
+}
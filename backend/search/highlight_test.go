@@ -0,0 +1,57 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "红楼", want: []string{"红楼"}},
+		{query: "红楼梦", want: []string{"红楼梦", "红楼", "楼梦"}},
+		{query: "Go 语言, 实战", want: []string{"Go", "语言", "实战"}},
+		{query: "Go语言", want: []string{"Go语言", "语言"}},
+		{query: "  ！？ ", want: nil},
+	}
+	for _, tt := range tests {
+		if got := queryTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("queryTerms(%q) 返回 %q，期望 %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("前", 40) + "红楼梦" + strings.Repeat("后", 80)
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  []string
+	}{
+		{name: "未命中", text: "围城", terms: []string{"红楼"}, want: nil},
+		{name: "空词", text: "围城", terms: []string{""}, want: nil},
+		{name: "单个命中", text: "红楼梦", terms: []string{"红楼"}, want: []string{"<mark>红楼</mark>梦"}},
+		{name: "重叠的命中合并", text: "红楼梦", terms: []string{"红楼", "楼梦"}, want: []string{"<mark>红楼梦</mark>"}},
+		{name: "不区分大小写并保留原文", text: "The Go Book", terms: []string{"go"}, want: []string{"The <mark>Go</mark> Book"}},
+		{name: "多处命中", text: "go and GO", terms: []string{"go"}, want: []string{"<mark>go</mark> and <mark>GO</mark>"}},
+		{name: "转义 HTML", text: "<b>红楼</b>", terms: []string{"红楼"}, want: []string{"&lt;b&gt;<mark>红楼</mark>&lt;/b&gt;"}},
+		{
+			name:  "长文本截取片段",
+			text:  long,
+			terms: []string{"红楼梦"},
+			want:  []string{"…" + strings.Repeat("前", 30) + "<mark>红楼梦</mark>" + strings.Repeat("后", 60) + "…"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.text, tt.terms); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("highlight(%q) 返回 %q，期望 %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
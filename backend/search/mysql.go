@@ -0,0 +1,87 @@
+package search
+
+import (
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+const (
+	fulltextIndexName = "idx_books_fulltext"
+	matchExpr         = "MATCH(title, author, summary) AGAINST (? IN NATURAL LANGUAGE MODE)"
+)
+
+// MySQLIndex 基于 MySQL FULLTEXT 索引（ngram 分词）的检索，数据写入即生效，无需单独维护索引
+type MySQLIndex struct {
+	db *gorm.DB
+}
+
+// NewMySQLIndex 确保 books 表上存在 ngram 全文索引
+func NewMySQLIndex(db *gorm.DB) (*MySQLIndex, error) {
+	var count int64
+	if err := db.Raw(
+		"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		"books", fulltextIndexName,
+	).Scan(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		if err := db.Exec("CREATE FULLTEXT INDEX " + fulltextIndexName + " ON books (title, author, summary) WITH PARSER ngram").Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &MySQLIndex{db: db}, nil
+}
+
+func (m *MySQLIndex) IndexBooks(books ...models.Book) error {
+	return nil
+}
+
+func (m *MySQLIndex) DeleteBooks(ids ...uint) error {
+	return nil
+}
+
+func (m *MySQLIndex) Search(query string, offset, limit int) (*Result, error) {
+	result := &Result{Hits: []Hit{}}
+
+	if err := m.db.Model(&models.Book{}).Where(matchExpr, query).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		ID      uint
+		Title   string
+		Author  string
+		Summary string
+		Score   float64
+	}
+	if err := m.db.Model(&models.Book{}).
+		Select("id, title, author, summary, "+matchExpr+" AS score", query).
+		Where(matchExpr, query).
+		Order("score DESC").Order("id DESC").
+		Offset(offset).Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	terms := queryTerms(query)
+	for _, row := range rows {
+		hit := Hit{BookID: row.ID, Score: row.Score, Highlights: map[string][]string{}}
+		for field, text := range map[string]string{"title": row.Title, "author": row.Author, "summary": row.Summary} {
+			if fragments := highlight(text, terms); fragments != nil {
+				hit.Highlights[field] = fragments
+			}
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+
+	return result, nil
+}
+
+func (m *MySQLIndex) Close() error {
+	return nil
+}
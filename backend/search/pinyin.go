@@ -0,0 +1,72 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinMaxLen 拼音列的长度上限，与 models.Book 中的 size 一致
+const pinyinMaxLen = 255
+
+var pinyinArgs = pinyin.NewArgs()
+
+// ToPinyin 返回全拼和首字母：汉字转为不带声调的拼音（多音字取常用读音），英文和数字转小写保留，其余字符忽略
+// 例如 "红楼梦" -> ("hongloumeng", "hlm")，"Go语言实战" -> ("goyuyanshizhan", "gyysz")
+func ToPinyin(s string) (full, initials string) {
+	var fb, ib strings.Builder
+	inWord := false
+
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			inWord = false
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 && py[0] != "" {
+				fb.WriteString(py[0])
+				ib.WriteByte(py[0][0])
+			}
+			continue
+		}
+		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			r = unicode.ToLower(r)
+			fb.WriteRune(r)
+			if !inWord {
+				ib.WriteRune(r)
+				inWord = true
+			}
+			continue
+		}
+		inWord = false
+	}
+
+	return truncate(fb.String(), pinyinMaxLen), truncate(ib.String(), pinyinMaxLen)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// PinyinKey 判断输入是否可能是拼音（只含字母、数字、空格和隔音符），是则返回去掉分隔后的小写形式，否则返回空字符串
+func PinyinKey(q string) string {
+	var b strings.Builder
+	hasLetter := false
+	for _, r := range q {
+		switch {
+		case r <= unicode.MaxASCII && unicode.IsLetter(r):
+			hasLetter = true
+			b.WriteRune(unicode.ToLower(r))
+		case r <= unicode.MaxASCII && unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == ' ' || r == '\'':
+		default:
+			return ""
+		}
+	}
+	if !hasLetter {
+		return ""
+	}
+	return b.String()
+}
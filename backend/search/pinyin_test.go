@@ -0,0 +1,54 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToPinyin(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantFull     string
+		wantInitials string
+	}{
+		{text: "红楼梦", wantFull: "hongloumeng", wantInitials: "hlm"},
+		{text: "Go语言实战", wantFull: "goyuyanshizhan", wantInitials: "gyysz"},
+		{text: "三体 2", wantFull: "santi2", wantInitials: "st2"},
+		{text: "The C++ Book", wantFull: "thecbook", wantInitials: "tcb"},
+		{text: "钱锺书", wantFull: "qianzhongshu", wantInitials: "qzs"},
+		{text: "《围城》", wantFull: "weicheng", wantInitials: "wc"},
+		{text: "", wantFull: "", wantInitials: ""},
+	}
+	for _, tt := range tests {
+		full, initials := ToPinyin(tt.text)
+		if full != tt.wantFull || initials != tt.wantInitials {
+			t.Errorf("ToPinyin(%q) 返回 %q, %q，期望 %q, %q", tt.text, full, initials, tt.wantFull, tt.wantInitials)
+		}
+	}
+
+	full, initials := ToPinyin(strings.Repeat("红", 100))
+	if len(full) != pinyinMaxLen || len(initials) != 100 {
+		t.Errorf("长文本拼音长度为 %d/%d，期望截断为 %d/100", len(full), len(initials), pinyinMaxLen)
+	}
+}
+
+func TestPinyinKey(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "hlm", want: "hlm"},
+		{query: "Hong Lou Meng", want: "hongloumeng"},
+		{query: "xi'an", want: "xian"},
+		{query: "santi2", want: "santi2"},
+		{query: "123", want: ""},
+		{query: "红楼", want: ""},
+		{query: "c++", want: ""},
+		{query: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := PinyinKey(tt.query); got != tt.want {
+			t.Errorf("PinyinKey(%q) 返回 %q，期望 %q", tt.query, got, tt.want)
+		}
+	}
+}
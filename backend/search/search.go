@@ -0,0 +1,118 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"gorm.io/gorm"
+)
+
+// 索引实现
+const (
+	BackendMySQL = "mysql" // MySQL FULLTEXT + ngram 分词，索引由数据库维护
+	BackendBleve = "bleve" // 进程内倒排索引（CJK 二元分词），启动时全量构建
+)
+
+// Hit 一条检索结果，Highlights 为各字段中命中片段，命中词以 <mark> 包裹，其余文本已做 HTML 转义
+type Hit struct {
+	BookID     uint
+	Score      float64
+	Highlights map[string][]string
+}
+
+// Result 检索结果，Hits 按相关度降序
+type Result struct {
+	Total int64
+	Hits  []Hit
+}
+
+// Index 图书全文检索，书名、作者、简介联合检索
+type Index interface {
+	// IndexBooks 新增或覆盖图书的索引
+	IndexBooks(books ...models.Book) error
+	// DeleteBooks 从索引中移除图书
+	DeleteBooks(ids ...uint) error
+	Search(query string, offset, limit int) (*Result, error)
+	Close() error
+}
+
+// Books 全局图书索引，在 main 中通过 Open 初始化
+var Books Index
+
+// BookHit 接口返回的检索结果
+type BookHit struct {
+	Book       models.Book         `json:"book"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights"`
+}
+
+var ErrUnknownBackend = errors.New("未知的搜索实现")
+
+// Open 按名称创建索引实现
+func Open(db *gorm.DB, backend string) (Index, error) {
+	switch backend {
+	case "", BackendMySQL:
+		return NewMySQLIndex(db)
+	case BackendBleve:
+		idx, err := NewBleveIndex()
+		if err != nil {
+			return nil, err
+		}
+		if err := Rebuild(db, idx); err != nil {
+			idx.Close()
+			return nil, err
+		}
+		return idx, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, backend)
+}
+
+// Rebuild 从数据库全量重建索引
+func Rebuild(db *gorm.DB, idx Index) error {
+	var batch []models.Book
+	return db.Model(&models.Book{}).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		return idx.IndexBooks(batch...)
+	}).Error
+}
+
+// SyncBooks 在写操作提交后调用：按 ID 重新读取图书更新索引，已不存在的图书从索引中移除，并使联想索引失效
+// 索引同步失败不影响业务操作，只记录日志
+func SyncBooks(db *gorm.DB, ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+	invalidateSuggestions()
+	if Books == nil {
+		return
+	}
+
+	var books []models.Book
+	if err := db.Where("id IN ?", ids).Find(&books).Error; err != nil {
+		log.Printf("同步搜索索引失败: %v", err)
+		return
+	}
+
+	found := make(map[uint]bool, len(books))
+	for _, b := range books {
+		found[b.ID] = true
+	}
+	var missing []uint
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(books) > 0 {
+		if err := Books.IndexBooks(books...); err != nil {
+			log.Printf("同步搜索索引失败: %v", err)
+		}
+	}
+	if len(missing) > 0 {
+		if err := Books.DeleteBooks(missing...); err != nil {
+			log.Printf("同步搜索索引失败: %v", err)
+		}
+	}
+}
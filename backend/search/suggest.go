@@ -0,0 +1,333 @@
+package search
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Suggester 书名和作者的前缀联想索引
+// 索引在首次查询时从数据库构建，图书写入后由 Invalidate 整体失效，下次查询时重建
+type Suggester interface {
+	Suggest(q string, limit int) ([]models.BookSuggestion, error)
+	Invalidate() error
+}
+
+// Suggestions 全局联想索引，启动时按 Redis 是否可用选择实现
+var Suggestions Suggester
+
+// suggestScanFactor 每个前缀最多扫描的词条数，同一书名可能有原文、全拼、首字母多个词条，去重后再截断
+const suggestScanFactor = 5
+
+// suggestEntry 一个联想词条，key 为用于前缀匹配的小写文本（原文、全拼或首字母）
+type suggestEntry struct {
+	key    string
+	field  string
+	bookID uint
+	text   string
+}
+
+// member 词条编码为 key\x00field\x00id\x00text，按字典序排列即按 key 排列
+func (e suggestEntry) member() string {
+	return e.key + "\x00" + e.field + "\x00" + strconv.FormatUint(uint64(e.bookID), 10) + "\x00" + e.text
+}
+
+func parseSuggestMember(m string) (suggestEntry, bool) {
+	parts := strings.SplitN(m, "\x00", 4)
+	if len(parts) != 4 {
+		return suggestEntry{}, false
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return suggestEntry{}, false
+	}
+	return suggestEntry{key: parts[0], field: parts[1], bookID: uint(id), text: parts[3]}, true
+}
+
+// loadSuggestEntries 读取全部图书，为书名、作者及其全拼、首字母各生成一个词条
+func loadSuggestEntries(db *gorm.DB) ([]suggestEntry, error) {
+	var entries []suggestEntry
+	var batch []models.Book
+	err := db.Select("id", "title", "author", "title_pinyin", "title_initials", "author_pinyin", "author_initials").
+		FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+			for _, b := range batch {
+				entries = appendSuggestEntries(entries, b.ID, "title", b.Title, b.TitlePinyin, b.TitleInitials)
+				entries = appendSuggestEntries(entries, b.ID, "author", b.Author, b.AuthorPinyin, b.AuthorInitials)
+			}
+			return nil
+		}).Error
+	return entries, err
+}
+
+func appendSuggestEntries(entries []suggestEntry, id uint, field, text string, keys ...string) []suggestEntry {
+	seen := make(map[string]bool, len(keys)+1)
+	for _, key := range append([]string{strings.ToLower(text)}, keys...) {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, suggestEntry{key: key, field: field, bookID: id, text: text})
+	}
+	return entries
+}
+
+// suggestPrefixes 返回需要查询的前缀：小写原文，以及看起来像拼音时去掉空格和隔音符的形式
+func suggestPrefixes(q string) []string {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" {
+		return nil
+	}
+	prefixes := []string{q}
+	if key := PinyinKey(q); key != "" && key != q {
+		prefixes = append(prefixes, key)
+	}
+	return prefixes
+}
+
+// rankSuggestions 同一字段的相同文本只保留一条，书名在前，较短的文本在前
+func rankSuggestions(entries []suggestEntry, limit int) []models.BookSuggestion {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.field != b.field {
+			return a.field == "title"
+		}
+		if len(a.text) != len(b.text) {
+			return len(a.text) < len(b.text)
+		}
+		if a.text != b.text {
+			return a.text < b.text
+		}
+		return a.bookID < b.bookID
+	})
+
+	suggestions := make([]models.BookSuggestion, 0, limit)
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		key := e.field + "\x00" + e.text
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		suggestions = append(suggestions, models.BookSuggestion{BookID: e.bookID, Field: e.field, Text: e.text})
+		if len(suggestions) == limit {
+			break
+		}
+	}
+	return suggestions
+}
+
+// MemorySuggester 进程内的联想索引，词条按编码排序后二分查找前缀，适用于单实例部署
+type MemorySuggester struct {
+	db *gorm.DB
+
+	mu      sync.RWMutex
+	members []string
+	built   bool
+	gen     uint64
+}
+
+func NewMemorySuggester(db *gorm.DB) *MemorySuggester {
+	return &MemorySuggester{db: db}
+}
+
+func (m *MemorySuggester) Suggest(q string, limit int) ([]models.BookSuggestion, error) {
+	prefixes := suggestPrefixes(q)
+	if len(prefixes) == 0 || limit <= 0 {
+		return []models.BookSuggestion{}, nil
+	}
+
+	members, err := m.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []suggestEntry
+	for _, prefix := range prefixes {
+		i := sort.SearchStrings(members, prefix)
+		for n := 0; i < len(members) && n < limit*suggestScanFactor; i, n = i+1, n+1 {
+			if !strings.HasPrefix(members[i], prefix) {
+				break
+			}
+			if e, ok := parseSuggestMember(members[i]); ok {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return rankSuggestions(entries, limit), nil
+}
+
+// snapshot 返回当前词条，未构建时从数据库构建
+// 构建期间发生失效时本次结果照常返回，但不缓存，下次查询重新构建
+func (m *MemorySuggester) snapshot() ([]string, error) {
+	m.mu.RLock()
+	if m.built {
+		members := m.members
+		m.mu.RUnlock()
+		return members, nil
+	}
+	gen := m.gen
+	m.mu.RUnlock()
+
+	entries, err := loadSuggestEntries(m.db)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, len(entries))
+	for i, e := range entries {
+		members[i] = e.member()
+	}
+	sort.Strings(members)
+
+	m.mu.Lock()
+	if m.gen == gen {
+		m.members = members
+		m.built = true
+	}
+	m.mu.Unlock()
+	return members, nil
+}
+
+func (m *MemorySuggester) Invalidate() error {
+	m.mu.Lock()
+	m.members = nil
+	m.built = false
+	m.gen++
+	m.mu.Unlock()
+	return nil
+}
+
+const (
+	redisSuggestKey    = "suggest:books"
+	redisSuggestGenKey = "suggest:books:gen"
+	// redisSuggestTTL 兜底过期时间，绕过接口直接修改数据库时联想结果最迟在此之后恢复一致
+	redisSuggestTTL = 24 * time.Hour
+)
+
+// RedisSuggester 基于 Redis 有序集合的联想索引，所有词条分值为 0，用 ZRANGEBYLEX 做前缀查询，多实例共享
+type RedisSuggester struct {
+	db   *gorm.DB
+	pool *redis.Pool
+}
+
+func NewRedisSuggester(db *gorm.DB, pool *redis.Pool) *RedisSuggester {
+	return &RedisSuggester{db: db, pool: pool}
+}
+
+func (r *RedisSuggester) Suggest(q string, limit int) ([]models.BookSuggestion, error) {
+	prefixes := suggestPrefixes(q)
+	if len(prefixes) == 0 || limit <= 0 {
+		return []models.BookSuggestion{}, nil
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	exists, err := redis.Bool(conn.Do("EXISTS", redisSuggestKey))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := r.build(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []suggestEntry
+	for _, prefix := range prefixes {
+		members, err := redis.Strings(conn.Do("ZRANGEBYLEX", redisSuggestKey,
+			"["+prefix, "["+prefix+"\xff", "LIMIT", 0, limit*suggestScanFactor))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			if e, ok := parseSuggestMember(m); ok {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return rankSuggestions(entries, limit), nil
+}
+
+// build 先写入临时键，再在代数未变化时原子地改名为正式键，避免构建期间的写入被旧数据覆盖
+func (r *RedisSuggester) build(conn redis.Conn) error {
+	gen, err := redis.Int64(conn.Do("GET", redisSuggestGenKey))
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	entries, err := loadSuggestEntries(r.db)
+	if err != nil {
+		return err
+	}
+
+	tmp := redisSuggestKey + ":build:" + uuid.NewString()
+	defer conn.Do("DEL", tmp)
+
+	// 空成员作为占位，没有图书时正式键也存在，不会每次查询都重建；它排在最前且不匹配任何非空前缀
+	args := redis.Args{tmp, 0, ""}
+	for i, e := range entries {
+		args = args.Add(0, e.member())
+		if (i+1)%500 == 0 {
+			if _, err := conn.Do("ZADD", args...); err != nil {
+				return err
+			}
+			args = redis.Args{tmp}
+		}
+	}
+	if len(args) > 1 {
+		if _, err := conn.Do("ZADD", args...); err != nil {
+			return err
+		}
+	}
+
+	if _, err := conn.Do("WATCH", redisSuggestGenKey); err != nil {
+		return err
+	}
+	current, err := redis.Int64(conn.Do("GET", redisSuggestGenKey))
+	if err != nil && err != redis.ErrNil {
+		conn.Do("UNWATCH")
+		return err
+	}
+	if current != gen {
+		conn.Do("UNWATCH")
+		return nil
+	}
+
+	conn.Send("MULTI")
+	conn.Send("RENAME", tmp, redisSuggestKey)
+	conn.Send("EXPIRE", redisSuggestKey, int(redisSuggestTTL.Seconds()))
+	if _, err := conn.Do("EXEC"); err != nil {
+		return fmt.Errorf("写入联想索引失败: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisSuggester) Invalidate() error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("INCR", redisSuggestGenKey)
+	conn.Send("DEL", redisSuggestKey)
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// invalidateSuggestions 图书写入后使联想索引失效，失败只记录日志
+func invalidateSuggestions() {
+	if Suggestions == nil {
+		return
+	}
+	if err := Suggestions.Invalidate(); err != nil {
+		log.Printf("联想索引失效失败: %v", err)
+	}
+}
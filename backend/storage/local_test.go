@@ -0,0 +1,26 @@
+package storage
+
+import "testing"
+
+func TestLocalURLPath(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		wantURL string
+		want    string
+	}{
+		{"", "/uploads", "/uploads"},
+		{"/", "/uploads", "/uploads"},
+		{"/static", "/static/uploads", "/static/uploads"},
+		{"/static/", "/static/uploads", "/static/uploads"},
+		{"https://cdn.example.com/lib/", "https://cdn.example.com/lib/uploads", "/lib/uploads"},
+	}
+	for _, tt := range tests {
+		l := NewLocal(".", tt.baseURL)
+		if got := l.URL("uploads"); got != tt.wantURL {
+			t.Errorf("baseURL=%q: URL = %q，期望 %q", tt.baseURL, got, tt.wantURL)
+		}
+		if got := l.URLPath("uploads"); got != tt.want {
+			t.Errorf("baseURL=%q: URLPath = %q，期望 %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// 存储后端
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound       = errors.New("文件不存在")
+	ErrInvalidKey     = errors.New("无效的文件路径")
+	ErrUnknownBackend = errors.New("不支持的存储后端")
+)
+
+// Storage 上传文件的存储后端，key 为以 / 分隔的相对路径，如 "uploads/cover_1700000000_ab12cd34.jpg"
+type Storage interface {
+	// Save 写入文件，size 未知时传 -1
+	Save(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 返回客户端访问文件的地址
+	URL(key string) string
+	// Open 读取文件，不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// List 递归列出目录 prefix 下的全部文件
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// Object List 返回的文件信息
+type Object struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Covers 封面存储，启动时按配置替换；默认为当前目录下的本地存储，与 r.Static("/uploads") 对应
+var Covers Storage = NewLocal(".", "/")
+
+// Config 存储配置，Backend 为 local 时只使用 Local* 字段，为 s3 时只使用 S3* 字段
+type Config struct {
+	Backend string
+
+	LocalRoot    string // 本地根目录，key 相对于该目录
+	LocalBaseURL string // 本地文件的访问前缀
+
+	S3Endpoint  string // 如 minio:9000、s3.amazonaws.com
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	S3PublicURL string // 对外访问前缀（如 CDN 地址），为空时按 endpoint/bucket 生成
+}
+
+// Open 按配置创建存储后端
+func Open(cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case "", BackendLocal:
+		return NewLocal(cfg.LocalRoot, cfg.LocalBaseURL), nil
+	case BackendS3:
+		return NewS3(cfg)
+	}
+	return nil, ErrUnknownBackend
+}
+
+// cleanKey 规范化 key，拒绝绝对路径和跳出根目录的路径
+func cleanKey(key string) (string, error) {
+	key = path.Clean(strings.ReplaceAll(key, "\\", "/"))
+	if key == "." || strings.HasPrefix(key, "/") || key == ".." || strings.HasPrefix(key, "../") {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
+
+// ContentType 按扩展名推断 Content-Type
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -1,14 +1,24 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime/multipart"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/Dailiduzhou/library_manage_sys/images"
 	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -31,55 +41,146 @@ func ComparePassword(dbPassword, password string) error {
 	return nil
 }
 
-func SaveImages(c *gin.Context, file *multipart.FileHeader) (string, error) {
-	uplaodDir := "uploads"
+// 密码策略，bcrypt 只使用前 72 字节，超出部分不参与校验，因此限制最大长度
+const (
+	passwordMinLength = 8
+	passwordMaxBytes  = 72
+)
+
+var (
+	ErrPasswordTooShort    = fmt.Errorf("密码长度不能少于 %d 位", passwordMinLength)
+	ErrPasswordTooLong     = fmt.Errorf("密码长度不能超过 %d 字节", passwordMaxBytes)
+	ErrPasswordTooSimple   = errors.New("密码须同时包含字母和数字")
+	ErrPasswordHasUsername = errors.New("密码不能包含用户名")
+)
 
-	if _, err := os.Stat(uplaodDir); os.IsNotExist(err) {
-		os.Mkdir(uplaodDir, 0755)
+// CheckPasswordPolicy 检查新密码是否符合密码策略，用于修改和重置密码
+func CheckPasswordPolicy(password, username string) error {
+	if len([]rune(password)) < passwordMinLength {
+		return ErrPasswordTooShort
+	}
+	if len(password) > passwordMaxBytes {
+		return ErrPasswordTooLong
 	}
 
-	ext := filepath.Ext(file.Filename)
-	timestamp := time.Now().Unix()
-	randomStr := uuid.New().String()[:8]
-	newFileName := fmt.Sprintf("cover_%d_%s%s", timestamp, randomStr, ext)
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return ErrPasswordTooSimple
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return ErrPasswordHasUsername
+	}
+	return nil
+}
 
-	dst := filepath.Join(uplaodDir, newFileName)
+// SaveImages 校验上传的封面并生成各尺寸规格写入封面存储，返回主文件的 key（即 Book.CoverPath）
+func SaveImages(c *gin.Context, file *multipart.FileHeader) (string, error) {
+	if limit := images.CoverLimits.MaxBytes; limit > 0 && file.Size > limit {
+		return "", fmt.Errorf("%w，最大 %d KB", images.ErrTooLarge, limit>>10)
+	}
 
-	if err := c.SaveUploadedFile(file, dst); err != nil {
+	src, err := file.Open()
+	if err != nil {
 		return "", err
 	}
+	defer src.Close()
 
-	return filepath.ToSlash(dst), nil
+	return saveCover(c.Request.Context(), src)
 }
 
-func RemoveFile(filePath string) error {
-	log.Printf("【调试】尝试删除文件，路径: [%s]", filePath)
-	if filePath == "" {
-		log.Println("【调试】路径为空，跳过")
-		return nil
-	}
+// SaveImageReader 与 SaveImages 相同，从 Reader 读取封面，格式按内容识别
+func SaveImageReader(src io.Reader) (string, error) {
+	return saveCover(context.Background(), src)
+}
 
-	absInputPath, err := filepath.Abs(filePath)
+// saveCover 写入 images.Variants 中的全部规格，任一规格写入失败时删除已写入的文件
+func saveCover(ctx context.Context, src io.Reader) (string, error) {
+	encoded, err := images.Process(src, images.CoverLimits)
 	if err != nil {
+		return "", err
+	}
+
+	base := newCoverBase()
+	var saved []string
+	var fullKey string
+	for _, e := range encoded {
+		key := base + "_" + e.Variant + e.Ext
+		if err := storage.Covers.Save(ctx, key, bytes.NewReader(e.Data), int64(len(e.Data)), e.ContentType); err != nil {
+			for _, k := range saved {
+				storage.Covers.Delete(context.Background(), k)
+			}
+			return "", err
+		}
+		saved = append(saved, key)
+		if e.Variant == images.VariantFull {
+			fullKey = key
+		}
+	}
+	return fullKey, nil
+}
+
+// newCoverBase 生成不含规格后缀和扩展名的封面 key
+func newCoverBase() string {
+	timestamp := time.Now().Unix()
+	randomStr := uuid.New().String()[:8]
+	return path.Join("uploads", fmt.Sprintf("cover_%d_%s", timestamp, randomStr))
+}
+
+// defaultCover 内置的默认封面占位图，部署时未提供 uploads/default.png 时使用
+//
+//go:embed default_cover.png
+var defaultCover []byte
+
+// EnsureDefaultCover 确保封面存储中存在默认封面，缺失时写入：优先使用本地的 uploads/default.png，没有时使用内置占位图
+// 对象存储或本地存储换了根目录时，默认封面不会自动出现在存储中，需在启动时补齐
+func EnsureDefaultCover(ctx context.Context, store storage.Storage) error {
+	r, err := store.Open(ctx, models.DefaultCoverPath)
+	if err == nil {
+		return r.Close()
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
 		return err
 	}
 
-	absDefaultPath, err := filepath.Abs(models.DefaultCoverPath)
+	data, err := os.ReadFile(filepath.FromSlash(models.DefaultCoverPath))
 	if err != nil {
+		data = defaultCover
+	}
+	if err := store.Save(ctx, models.DefaultCoverPath, bytes.NewReader(data), int64(len(data)), storage.ContentType(models.DefaultCoverPath)); err != nil {
 		return err
 	}
+	log.Printf("封面存储中缺少默认封面，已写入 %s", models.DefaultCoverPath)
+	return nil
+}
 
-	if absInputPath == absDefaultPath {
-		log.Println("【调试】检测到是默认封面，触发保护，跳过删除")
+// RemoveFile 从封面存储中删除文件，默认封面受保护不会被删除
+func RemoveFile(filePath string) error {
+	log.Printf("【调试】尝试删除文件，路径: [%s]", filePath)
+	if filePath == "" {
+		log.Println("【调试】路径为空，跳过")
 		return nil
 	}
 
-	log.Printf("【执行】正在删除文件: %s", filePath)
-	err = os.Remove(absInputPath)
+	if path.Clean(filepath.ToSlash(filePath)) == models.DefaultCoverPath {
+		log.Println("【调试】检测到是默认封面，触发保护，跳过删除")
+		return nil
+	}
 
-	if err != nil && !os.IsNotExist(err) {
-		log.Printf("【错误】删除失败: %v", err)
-		return err
+	// 同时删除缩略图等其他规格
+	for _, key := range images.VariantKeys(filePath) {
+		log.Printf("【执行】正在删除文件: %s", key)
+		if err := storage.Covers.Delete(context.Background(), key); err != nil {
+			log.Printf("【错误】删除失败: %v", err)
+			return err
+		}
 	}
 
 	return nil
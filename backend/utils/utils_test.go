@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Dailiduzhou/library_manage_sys/models"
+	"github.com/Dailiduzhou/library_manage_sys/storage"
+)
+
+func TestEnsureDefaultCover(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store := storage.NewLocal(root, "/")
+
+	if err := EnsureDefaultCover(ctx, store); err != nil {
+		t.Fatalf("写入默认封面失败: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(models.DefaultCoverPath)))
+	if err != nil {
+		t.Fatalf("默认封面未写入: %v", err)
+	}
+	if !bytes.Equal(got, defaultCover) {
+		t.Errorf("写入的不是内置占位图")
+	}
+
+	// 已存在时不覆盖
+	custom := []byte("custom")
+	if err := store.Save(ctx, models.DefaultCoverPath, bytes.NewReader(custom), int64(len(custom)), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureDefaultCover(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Open(ctx, models.DefaultCoverPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, _ := io.ReadAll(r); !bytes.Equal(got, custom) {
+		t.Errorf("已有的默认封面被覆盖")
+	}
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     error
+	}{
+		{name: "符合策略", password: "library2024", username: "reader", want: nil},
+		{name: "刚好 8 位", password: "abcdef12", username: "reader", want: nil},
+		{name: "过短", password: "abc123", username: "reader", want: ErrPasswordTooShort},
+		{name: "中文按字符计长度", password: "图书馆借书1号", username: "reader", want: ErrPasswordTooShort},
+		{name: "中文密码", password: "图书馆借阅系统密码1", username: "reader", want: nil},
+		{name: "超过 72 字节", password: strings.Repeat("a1", 37), username: "reader", want: ErrPasswordTooLong},
+		{name: "刚好 72 字节", password: strings.Repeat("a1", 36), username: "reader", want: nil},
+		{name: "只有字母", password: "abcdefgh", username: "reader", want: ErrPasswordTooSimple},
+		{name: "只有数字", password: "12345678", username: "reader", want: ErrPasswordTooSimple},
+		{name: "包含用户名", password: "reader2024", username: "reader", want: ErrPasswordHasUsername},
+		{name: "包含用户名时不区分大小写", password: "MyReader2024", username: "reader", want: ErrPasswordHasUsername},
+		{name: "用户名为空", password: "reader2024", username: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPasswordPolicy(tt.password, tt.username); got != tt.want {
+				t.Errorf("CheckPasswordPolicy(%q, %q) 返回 %v，期望 %v", tt.password, tt.username, got, tt.want)
+			}
+		})
+	}
+}